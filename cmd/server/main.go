@@ -1,16 +1,23 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 
 	_ "agios/docs"
 
+	"agios/internal/config"
 	"agios/internal/database"
 	"agios/internal/handlers"
 	"agios/internal/repositories"
 	"agios/internal/services"
+	"agios/internal/tools"
+	"agios/internal/utils/llm"
+	"agios/internal/utils/reader"
+	"agios/internal/utils/resilience"
+	"agios/internal/utils/search"
 
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
@@ -44,6 +51,22 @@ func main() {
 		log.Fatal("Failed to connect to Qdrant:", err)
 	}
 
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+
+	resilience.Configure(cfg)
+
+	if err := llm.Init(cfg); err != nil {
+		log.Fatal("Failed to set up LLM providers:", err)
+	}
+	defer llm.Close()
+
+	llm.EnableCache(database.GetRedisClient(), cfg)
+	search.Init(cfg)
+	reader.Init(cfg)
+
 	e := echo.New()
 
 	e.Use(middleware.Logger())
@@ -51,7 +74,7 @@ func main() {
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins: []string{"*"},
 		AllowMethods: []string{echo.GET, echo.HEAD, echo.PUT, echo.PATCH, echo.POST, echo.DELETE},
-		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept},
+		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, "X-LLM-Cache"},
 	}))
 
 	db := database.GetDB()
@@ -60,6 +83,17 @@ func main() {
 	fileService := services.NewFileService(fileRepository)
 	threadRepository := repositories.NewThreadRepository(db)
 	messageRepository := repositories.NewMessageRepository(db)
+	eventStreamRepository := repositories.NewEventStreamRepository(database.GetRedisClient())
+	usageRepository := repositories.NewUsageRepository(db)
+	answerCacheRepository := repositories.NewAnswerCacheRepository(database.GetQdrantClient())
+	semanticCacheService := services.NewSemanticCacheService(answerCacheRepository, cfg)
+	if err := semanticCacheService.Prepare(context.Background()); err != nil {
+		log.Fatal("Failed to prepare the semantic answer cache:", err)
+	}
+	answerService := services.NewAnswerService(messageRepository, threadRepository, usageRepository, semanticCacheService, tools.NewDefaultRegistry(), cfg)
+	messageEventRepository := repositories.NewMessageEventRepository(db)
+	generationService := services.NewGenerationService(answerService, eventStreamRepository, messageRepository, messageEventRepository)
+	budgetService := services.NewBudgetService(usageRepository, cfg)
 
 	// @Summary Show the status of the server.
 	// @Description get the status of the server.
@@ -70,11 +104,19 @@ func main() {
 	// @Router /health [get]
 	e.GET("/health", handlers.HealthCheck)
 	e.POST("/api/v1/files/upload", handlers.UploadFileHandler(fileService))
-	e.POST("/api/v1/threads", handlers.CreateThreadHandler(threadRepository, messageRepository, fileRepository))
-	e.POST("/api/v1/threads/:threadId/messages", handlers.AddMessageToThread)
+	e.POST("/api/v1/threads", handlers.CreateThreadHandler(threadRepository, messageRepository, fileRepository, generationService, budgetService, cfg))
+	e.POST("/api/v1/threads/:threadId/messages", handlers.AddMessageToThreadHandler(threadRepository, messageRepository, fileRepository, generationService, budgetService, cfg))
 	e.GET("/api/v1/threads/:threadId", handlers.GetThreadHandler(threadRepository))
+	e.GET("/api/v1/threads/:threadId/branches", handlers.ListBranchesHandler(threadRepository))
+	e.PUT("/api/v1/threads/:threadId/active_branch", handlers.SwitchBranchHandler(threadRepository))
 	e.DELETE("/api/v1/threads/:threadId", handlers.DeleteThreadHandler(threadRepository))
 	e.DELETE("/api/v1/messages/:messageId", handlers.DeleteMessageHandler(messageRepository))
+	e.GET("/api/v1/messages/:messageId/events", handlers.StreamMessageEventsHandler(generationService))
+	e.POST("/api/v1/messages/:messageId/cancel", handlers.CancelMessageHandler(generationService))
+	e.POST("/api/v1/messages/:messageId/regenerate", handlers.RegenerateMessageHandler(generationService, budgetService, cfg))
+	e.POST("/api/v1/messages/:messageId/edit", handlers.EditMessageHandler(threadRepository, messageRepository, fileRepository, generationService, budgetService, cfg))
+
+	e.GET("/api/v1/usage", handlers.GetUsageHandler(usageRepository, budgetService))
 
 	e.GET("/docs/*", echoSwagger.WrapHandler)
 
@@ -107,6 +107,287 @@ const docTemplate = `{
                 }
             }
         },
+        "/api/v1/messages/{messageId}/cancel": {
+            "post": {
+                "description": "Stop generating the answer of a message and mark it FAILED with a cancellation reason",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Messages"
+                ],
+                "summary": "Cancel a message's generation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Message ID",
+                        "name": "messageId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Optional cancellation reason",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CancelMessageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Cancelled message",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "id": {
+                                    "type": "string"
+                                },
+                                "meta_data": {
+                                    "type": "string"
+                                },
+                                "stream_status": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid message ID format",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Message not found",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Message is not in progress",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/messages/{messageId}/edit": {
+            "post": {
+                "description": "Fork the message's thread into a new branch that keeps the earlier messages, answer the edited query there and stream the answer. The new branch becomes the active one.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "Messages"
+                ],
+                "summary": "Edit a past query",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Message ID",
+                        "name": "messageId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Edited query",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.EditMessageRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "bypass or refresh the LLM response cache",
+                        "name": "X-LLM-Cache",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "SSE stream; START carries the thread_id of the new branch",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Message not found",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "LLM spend budget exhausted",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/messages/{messageId}/events": {
+            "get": {
+                "description": "Replay the events of a message after Last-Event-ID, then follow the live stream until END",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "Messages"
+                ],
+                "summary": "Resume a message's event stream",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Message ID",
+                        "name": "messageId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID of the last event the client received",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Alternative to the Last-Event-ID header",
+                        "name": "last_event_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "SSE stream of the remaining events",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid message ID or event ID",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Event stream not found or expired",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/messages/{messageId}/regenerate": {
+            "post": {
+                "description": "Re-run the pipeline for the same query and files, optionally with another model, and stream the new answer. Earlier answers are kept as versions.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "Messages"
+                ],
+                "summary": "Regenerate a message's answer",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Message ID",
+                        "name": "messageId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Optional model override",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RegenerateMessageRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "bypass the LLM response cache; regeneration refreshes it by default",
+                        "name": "X-LLM-Cache",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "SSE stream of START, PLAN, WEB_RESULTS, MARKDOWN_ANSWER, WIDGET and END events",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Message not found",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Message is still being generated",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "LLM spend budget exhausted",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/api/v1/threads/{threadId}": {
             "get": {
                 "description": "Get a thread and its messages by thread ID",
@@ -138,6 +419,9 @@ const docTemplate = `{
                                 "created_at": {
                                     "type": "string"
                                 },
+                                "fork_message_index": {
+                                    "type": "integer"
+                                },
                                 "id": {
                                     "type": "string"
                                 },
@@ -146,36 +430,144 @@ const docTemplate = `{
                                     "items": {
                                         "type": "object",
                                         "properties": {
+                                            "cost": {
+                                                "type": "number"
+                                            },
                                             "created_at": {
                                                 "type": "string"
                                             },
                                             "event_type": {
                                                 "type": "string"
                                             },
+                                            "events": {
+                                                "type": "array",
+                                                "items": {
+                                                    "type": "object",
+                                                    "properties": {
+                                                        "created_at": {
+                                                            "type": "string"
+                                                        },
+                                                        "data": {
+                                                            "type": "object"
+                                                        },
+                                                        "event": {
+                                                            "type": "string"
+                                                        },
+                                                        "sequence": {
+                                                            "type": "integer"
+                                                        }
+                                                    }
+                                                }
+                                            },
                                             "id": {
                                                 "type": "string"
                                             },
+                                            "input_token": {
+                                                "type": "integer"
+                                            },
                                             "message_index": {
                                                 "type": "integer"
                                             },
                                             "meta_data": {
                                                 "type": "string"
                                             },
+                                            "model": {
+                                                "type": "string"
+                                            },
+                                            "output_token": {
+                                                "type": "integer"
+                                            },
                                             "query_text": {
                                                 "type": "string"
                                             },
                                             "response_text": {
                                                 "type": "string"
                                             },
+                                            "response_time": {
+                                                "type": "number"
+                                            },
+                                            "selected_version": {
+                                                "type": "integer"
+                                            },
                                             "stream_status": {
                                                 "type": "string"
                                             },
                                             "version": {
                                                 "type": "integer"
+                                            },
+                                            "versions": {
+                                                "type": "array",
+                                                "items": {
+                                                    "type": "object",
+                                                    "properties": {
+                                                        "cost": {
+                                                            "type": "number"
+                                                        },
+                                                        "created_at": {
+                                                            "type": "string"
+                                                        },
+                                                        "event_type": {
+                                                            "type": "string"
+                                                        },
+                                                        "events": {
+                                                            "type": "array",
+                                                            "items": {
+                                                                "type": "object",
+                                                                "properties": {
+                                                                    "created_at": {
+                                                                        "type": "string"
+                                                                    },
+                                                                    "data": {
+                                                                        "type": "object"
+                                                                    },
+                                                                    "event": {
+                                                                        "type": "string"
+                                                                    },
+                                                                    "sequence": {
+                                                                        "type": "integer"
+                                                                    }
+                                                                }
+                                                            }
+                                                        },
+                                                        "input_token": {
+                                                            "type": "integer"
+                                                        },
+                                                        "meta_data": {
+                                                            "type": "string"
+                                                        },
+                                                        "model": {
+                                                            "type": "string"
+                                                        },
+                                                        "output_token": {
+                                                            "type": "integer"
+                                                        },
+                                                        "response_text": {
+                                                            "type": "string"
+                                                        },
+                                                        "response_time": {
+                                                            "type": "number"
+                                                        },
+                                                        "selected": {
+                                                            "type": "boolean"
+                                                        },
+                                                        "stream_status": {
+                                                            "type": "string"
+                                                        },
+                                                        "version_index": {
+                                                            "type": "integer"
+                                                        }
+                                                    }
+                                                }
                                             }
                                         }
                                     }
                                 },
+                                "parent_thread_id": {
+                                    "type": "string"
+                                },
+                                "root_thread_id": {
+                                    "type": "string"
+                                },
                                 "slug": {
                                     "type": "string"
                                 },
@@ -253,9 +645,418 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
+        },
+        "/api/v1/threads/{threadId}/active_branch": {
+            "put": {
+                "description": "Make another branch of the thread's family the active one",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Threads"
+                ],
+                "summary": "Switch the active branch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thread ID of any branch in the family",
+                        "name": "threadId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Branch to switch to",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwitchBranchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Branches",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "active_branch_id": {
+                                    "type": "string"
+                                },
+                                "branches": {
+                                    "type": "array",
+                                    "items": {
+                                        "type": "object",
+                                        "properties": {
+                                            "active": {
+                                                "type": "boolean"
+                                            },
+                                            "created_at": {
+                                                "type": "string"
+                                            },
+                                            "fork_message_index": {
+                                                "type": "integer"
+                                            },
+                                            "id": {
+                                                "type": "string"
+                                            },
+                                            "parent_thread_id": {
+                                                "type": "string"
+                                            },
+                                            "slug": {
+                                                "type": "string"
+                                            }
+                                        }
+                                    }
+                                },
+                                "root_thread_id": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Thread or branch not found",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/threads/{threadId}/branches": {
+            "get": {
+                "description": "List the root thread and every branch forked from it by editing a message, with the active branch marked",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Threads"
+                ],
+                "summary": "List the branches of a thread",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thread ID of any branch in the family",
+                        "name": "threadId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Branches",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "active_branch_id": {
+                                    "type": "string"
+                                },
+                                "branches": {
+                                    "type": "array",
+                                    "items": {
+                                        "type": "object",
+                                        "properties": {
+                                            "active": {
+                                                "type": "boolean"
+                                            },
+                                            "created_at": {
+                                                "type": "string"
+                                            },
+                                            "fork_message_index": {
+                                                "type": "integer"
+                                            },
+                                            "id": {
+                                                "type": "string"
+                                            },
+                                            "parent_thread_id": {
+                                                "type": "string"
+                                            },
+                                            "slug": {
+                                                "type": "string"
+                                            }
+                                        }
+                                    }
+                                },
+                                "root_thread_id": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid thread ID format",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Thread not found",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/threads/{threadId}/messages": {
+            "post": {
+                "description": "Add a follow-up message to an existing thread and stream the answer as server-sent events",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "Threads"
+                ],
+                "summary": "Add a message to a thread",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thread ID",
+                        "name": "threadId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Follow-up query",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.AddMessageRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "bypass or refresh the LLM response cache",
+                        "name": "X-LLM-Cache",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "SSE stream of START, PLAN, WEB_RESULTS, MARKDOWN_ANSWER, WIDGET and END events",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Thread not found",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "LLM spend budget exhausted",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/usage": {
+            "get": {
+                "description": "Tokens, cost and request counts of the LLM calls in a date range, grouped by any of day, model, tool, stage and user, with the current budget status",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Usage"
+                ],
+                "summary": "Report LLM usage",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "day",
+                        "description": "Comma-separated dimensions: day, model, tool, stage, user",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "First day included (YYYY-MM-DD, UTC); defaults to 30 days ago",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last day included (YYYY-MM-DD, UTC); defaults to today",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Usage report",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "budget": {
+                                    "type": "object"
+                                },
+                                "from": {
+                                    "type": "string"
+                                },
+                                "group_by": {
+                                    "type": "array",
+                                    "items": {
+                                        "type": "string"
+                                    }
+                                },
+                                "rows": {
+                                    "type": "array",
+                                    "items": {
+                                        "type": "object",
+                                        "properties": {
+                                            "calls": {
+                                                "type": "integer"
+                                            },
+                                            "cost_usd": {
+                                                "type": "number"
+                                            },
+                                            "day": {
+                                                "type": "string"
+                                            },
+                                            "input_tokens": {
+                                                "type": "integer"
+                                            },
+                                            "model": {
+                                                "type": "string"
+                                            },
+                                            "output_tokens": {
+                                                "type": "integer"
+                                            },
+                                            "requests": {
+                                                "type": "integer"
+                                            },
+                                            "stage": {
+                                                "type": "string"
+                                            },
+                                            "tool": {
+                                                "type": "string"
+                                            },
+                                            "user": {
+                                                "type": "string"
+                                            }
+                                        }
+                                    }
+                                },
+                                "to": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/helpers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "handlers.AddMessageRequest": {
+            "type": "object",
+            "properties": {
+                "file_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "model": {
+                    "type": "string"
+                },
+                "query_text": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.CancelMessageRequest": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.EditMessageRequest": {
+            "type": "object",
+            "properties": {
+                "file_ids": {
+                    "description": "FileIDs replaces the files of the edited message; omit it to keep them.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "query_text": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RegenerateMessageRequest": {
+            "type": "object",
+            "properties": {
+                "model": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.SwitchBranchRequest": {
+            "type": "object",
+            "properties": {
+                "branch_id": {
+                    "type": "string"
+                }
+            }
+        },
         "helpers.ErrorResponse": {
             "type": "object",
             "properties": {
@@ -2,6 +2,10 @@ package config
 
 import (
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -29,8 +33,98 @@ type Config struct {
 	UpstashAPIKey    string
 	UpstashURL       string
 	CurrentLLMModel  string
+	GeminiAPIKey     string
+	// GeminiClientPool is the number of Gemini clients kept open for reuse.
+	GeminiClientPool int
+	// LLMProvider serves model names without a "<provider>/" prefix:
+	// gemini (default), cerebras, openai, ollama, vllm, llamacpp or fake.
+	LLMProvider string
+	// Base URLs of OpenAI-compatible servers; a provider is available once
+	// its URL (or, for OpenAI itself, its key) is set.
+	OpenAIBaseURL   string
+	OpenAIAPIKey    string
+	OllamaBaseURL   string
+	VLLMBaseURL     string
+	LlamaCppBaseURL string
+	// FakeLLMScript is the JSON script of the fake provider.
+	FakeLLMScript string
+	// StageModels routes pipeline stages (constant.Stage*) to models;
+	// stages not listed use CurrentLLMModel.
+	StageModels map[string]string
+	// LLMCache turns on the exact-match response cache in Redis. Entries
+	// live LLMCacheTTL, or the TTL listed for their stage in
+	// LLMCacheStageTTLs; a zero TTL leaves the stage uncached.
+	LLMCache          bool
+	LLMCacheTTL       time.Duration
+	LLMCacheStageTTLs map[string]time.Duration
+	// SemanticCache reuses answers of reusable tools for queries whose
+	// embedding is at least SemanticCacheThreshold similar to a query
+	// answered within SemanticCacheMaxAge.
+	SemanticCache          bool
+	SemanticCacheThreshold float64
+	SemanticCacheMaxAge    time.Duration
+	// EmbeddingModel embeds the queries of the semantic cache, named as
+	// "<provider>/<model>" like any other model; its provider must support
+	// embeddings.
+	EmbeddingModel string
+	// AllowedLLMModels lists the models a request may ask for.
+	AllowedLLMModels []string
+	// DailyBudgetUSD and MonthlyBudgetUSD cap LLM spend per UTC day and
+	// month; 0 disables a cap. Once one is spent, BudgetAction "reject"
+	// refuses new requests and "downgrade" answers them with
+	// BudgetFallbackModel.
+	DailyBudgetUSD      float64
+	MonthlyBudgetUSD    float64
+	BudgetAction        string
+	BudgetFallbackModel string
+	// Calls to external services are retried up to RetryMaxAttempts times
+	// with exponential backoff between RetryBaseDelay and RetryMaxDelay.
+	// BreakerFailures consecutive failures of a service stop calls to it
+	// for BreakerCooldown.
+	RetryMaxAttempts int
+	RetryBaseDelay   time.Duration
+	RetryMaxDelay    time.Duration
+	BreakerFailures  int
+	BreakerCooldown  time.Duration
+	// ModelFallbacks lists the models tried, in order, when a model is
+	// unavailable.
+	ModelFallbacks map[string][]string
+	// SearchProviders are the web search providers queried in parallel;
+	// empty means every provider with credentials. Their merged results
+	// are cut to SearchMaxResults, and each waits at most SearchTimeout.
+	// SearchConcurrency queries of a multi-query search run at a time.
+	SearchProviders   []string
+	SearXNGURL        string
+	SearchMaxResults  int
+	SearchTimeout     time.Duration
+	SearchConcurrency int
+	// PageReaderPages top search results are fetched, each within
+	// PageReaderTimeout and PageReaderMaxBytes, and their
+	// PageReaderPassages most relevant passages given to the model;
+	// 0 pages turns the reader off.
+	PageReaderPages    int
+	PageReaderTimeout  time.Duration
+	PageReaderMaxBytes int64
+	PageReaderPassages int
+	// CitationMode is what happens to [n] markers citing no source:
+	// "drop" (default) removes them from the answer, "flag" keeps them.
+	CitationMode string
+	// QueryRewriteTurns earlier messages are used to rewrite a follow-up
+	// into a standalone query; 0 turns rewriting off.
+	QueryRewriteTurns int
+	// HistoryTokenBudget caps the estimated tokens of earlier turns given
+	// to the answering model; HistoryTokenBudgets overrides it per model.
+	// Turns beyond the budget are replaced by a running summary.
+	HistoryTokenBudget  int
+	HistoryTokenBudgets map[string]int
 }
 
+// Budget actions.
+const (
+	BudgetActionReject    = "reject"
+	BudgetActionDowngrade = "downgrade"
+)
+
 func LoadConfig() (*Config, error) {
 	godotenv.Load()
 
@@ -57,11 +151,237 @@ func LoadConfig() (*Config, error) {
 		UpstashAPIKey:    os.Getenv("UPSTASH_API_KEY"),
 		UpstashURL:       os.Getenv("UPSTASH_URL"),
 		CurrentLLMModel:  os.Getenv("DEFAULT_LLM_MODEL"),
+		GeminiAPIKey:     os.Getenv("GEMINI_API_KEY"),
+		GeminiClientPool: envInt("GEMINI_CLIENT_POOL"),
+
+		LLMProvider:     os.Getenv("LLM_PROVIDER"),
+		OpenAIBaseURL:   os.Getenv("OPENAI_BASE_URL"),
+		OpenAIAPIKey:    os.Getenv("OPENAI_API_KEY"),
+		OllamaBaseURL:   os.Getenv("OLLAMA_BASE_URL"),
+		VLLMBaseURL:     os.Getenv("VLLM_BASE_URL"),
+		LlamaCppBaseURL: os.Getenv("LLAMACPP_BASE_URL"),
+		FakeLLMScript:   os.Getenv("FAKE_LLM_SCRIPT"),
+
+		DailyBudgetUSD:      envFloat("DAILY_BUDGET_USD"),
+		MonthlyBudgetUSD:    envFloat("MONTHLY_BUDGET_USD"),
+		BudgetAction:        os.Getenv("BUDGET_ACTION"),
+		BudgetFallbackModel: os.Getenv("BUDGET_FALLBACK_MODEL"),
+	}
+
+	if cfg.BudgetAction != BudgetActionDowngrade {
+		cfg.BudgetAction = BudgetActionReject
+	}
+	if cfg.BudgetFallbackModel == "" {
+		cfg.BudgetFallbackModel = "gemini-1.5-flash-8b"
+	}
+
+	if cfg.GeminiClientPool <= 0 {
+		cfg.GeminiClientPool = 4
+	}
+	if cfg.LLMProvider == "" {
+		cfg.LLMProvider = "gemini"
+	}
+	if cfg.OpenAIBaseURL == "" && cfg.OpenAIAPIKey != "" {
+		cfg.OpenAIBaseURL = "https://api.openai.com/v1"
 	}
 
 	if cfg.CurrentLLMModel == "" {
 		cfg.CurrentLLMModel = "gemini-1.5-flash"
 	}
 
+	cfg.LLMCache, _ = strconv.ParseBool(os.Getenv("LLM_CACHE"))
+	cfg.LLMCacheTTL = envDuration("LLM_CACHE_TTL", 24*time.Hour)
+	// Tool results go stale at their own pace: forecasts within the hour,
+	// city coordinates hardly ever.
+	cfg.LLMCacheStageTTLs = map[string]time.Duration{
+		"web_search": time.Hour,
+		"places":     24 * time.Hour,
+		"weather":    30 * time.Minute,
+		"geocode":    7 * 24 * time.Hour,
+	}
+	for _, entry := range strings.Split(os.Getenv("LLM_CACHE_STAGE_TTLS"), ",") {
+		stage, ttl, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		if d, err := time.ParseDuration(strings.TrimSpace(ttl)); err == nil && d >= 0 {
+			cfg.LLMCacheStageTTLs[strings.TrimSpace(stage)] = d
+		}
+	}
+
+	cfg.SemanticCache, _ = strconv.ParseBool(os.Getenv("SEMANTIC_CACHE"))
+	cfg.SemanticCacheThreshold = envFloat("SEMANTIC_CACHE_THRESHOLD")
+	if cfg.SemanticCacheThreshold <= 0 || cfg.SemanticCacheThreshold > 1 {
+		cfg.SemanticCacheThreshold = 0.92
+	}
+	cfg.SemanticCacheMaxAge = envDuration("SEMANTIC_CACHE_MAX_AGE", 6*time.Hour)
+	cfg.EmbeddingModel = os.Getenv("EMBEDDING_MODEL")
+	if cfg.EmbeddingModel == "" {
+		cfg.EmbeddingModel = "gemini/embedding-001"
+	}
+
+	cfg.RetryMaxAttempts = envInt("RETRY_MAX_ATTEMPTS")
+	if cfg.RetryMaxAttempts <= 0 {
+		cfg.RetryMaxAttempts = 3
+	}
+	cfg.RetryBaseDelay = envDuration("RETRY_BASE_DELAY", 250*time.Millisecond)
+	cfg.RetryMaxDelay = envDuration("RETRY_MAX_DELAY", 5*time.Second)
+	cfg.BreakerFailures = envInt("BREAKER_FAILURES")
+	if cfg.BreakerFailures <= 0 {
+		cfg.BreakerFailures = 5
+	}
+	cfg.BreakerCooldown = envDuration("BREAKER_COOLDOWN", 30*time.Second)
+
+	cfg.ModelFallbacks = map[string][]string{}
+	for _, chain := range strings.Split(os.Getenv("LLM_FALLBACKS"), ",") {
+		model, fallbacks, ok := strings.Cut(chain, "=")
+		if model = strings.TrimSpace(model); !ok || model == "" {
+			continue
+		}
+		for _, fb := range strings.Split(fallbacks, "|") {
+			if fb = strings.TrimSpace(fb); fb != "" && fb != model {
+				cfg.ModelFallbacks[model] = append(cfg.ModelFallbacks[model], fb)
+			}
+		}
+	}
+
+	for _, p := range strings.Split(os.Getenv("SEARCH_PROVIDERS"), ",") {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" && !slices.Contains(cfg.SearchProviders, p) {
+			cfg.SearchProviders = append(cfg.SearchProviders, p)
+		}
+	}
+	cfg.SearXNGURL = os.Getenv("SEARXNG_URL")
+	cfg.SearchMaxResults = envInt("SEARCH_MAX_RESULTS")
+	if cfg.SearchMaxResults <= 0 {
+		cfg.SearchMaxResults = 10
+	}
+	cfg.SearchTimeout = envDuration("SEARCH_TIMEOUT", 10*time.Second)
+	cfg.SearchConcurrency = envInt("SEARCH_CONCURRENCY")
+	if cfg.SearchConcurrency <= 0 {
+		cfg.SearchConcurrency = 3
+	}
+
+	cfg.PageReaderPages = 3
+	if v, err := strconv.Atoi(strings.TrimSpace(os.Getenv("PAGE_READER_PAGES"))); err == nil && v >= 0 {
+		cfg.PageReaderPages = v
+	}
+	cfg.PageReaderTimeout = envDuration("PAGE_READER_TIMEOUT", 8*time.Second)
+	cfg.PageReaderMaxBytes = int64(envInt("PAGE_READER_MAX_BYTES"))
+	if cfg.PageReaderMaxBytes <= 0 {
+		cfg.PageReaderMaxBytes = 2 << 20
+	}
+	cfg.PageReaderPassages = envInt("PAGE_READER_PASSAGES")
+	if cfg.PageReaderPassages <= 0 {
+		cfg.PageReaderPassages = 8
+	}
+
+	cfg.CitationMode = strings.ToLower(strings.TrimSpace(os.Getenv("CITATION_MODE")))
+	if cfg.CitationMode != "flag" {
+		cfg.CitationMode = "drop"
+	}
+
+	cfg.QueryRewriteTurns = 4
+	if v, err := strconv.Atoi(strings.TrimSpace(os.Getenv("QUERY_REWRITE_TURNS"))); err == nil && v >= 0 {
+		cfg.QueryRewriteTurns = v
+	}
+
+	cfg.HistoryTokenBudget = envInt("HISTORY_TOKEN_BUDGET")
+	if cfg.HistoryTokenBudget <= 0 {
+		cfg.HistoryTokenBudget = 4000
+	}
+	cfg.HistoryTokenBudgets = map[string]int{}
+	for _, entry := range strings.Split(os.Getenv("HISTORY_TOKEN_BUDGETS"), ",") {
+		model, budget, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		if v, err := strconv.Atoi(strings.TrimSpace(budget)); err == nil && v > 0 {
+			cfg.HistoryTokenBudgets[strings.TrimSpace(model)] = v
+		}
+	}
+
+	cfg.StageModels = map[string]string{}
+	for _, route := range strings.Split(os.Getenv("LLM_STAGE_MODELS"), ",") {
+		stage, model, ok := strings.Cut(route, "=")
+		if stage, model = strings.TrimSpace(stage), strings.TrimSpace(model); ok && stage != "" && model != "" {
+			cfg.StageModels[stage] = model
+		}
+	}
+
+	for _, m := range strings.Split(os.Getenv("ALLOWED_LLM_MODELS"), ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			cfg.AllowedLLMModels = append(cfg.AllowedLLMModels, m)
+		}
+	}
+	if !slices.Contains(cfg.AllowedLLMModels, cfg.CurrentLLMModel) {
+		cfg.AllowedLLMModels = append(cfg.AllowedLLMModels, cfg.CurrentLLMModel)
+	}
+
 	return cfg, nil
 }
+
+// ModelForStage returns the model routed to a pipeline stage.
+func (c *Config) ModelForStage(stage string) string {
+	if model, ok := c.StageModels[stage]; ok {
+		return model
+	}
+	return c.CurrentLLMModel
+}
+
+// CacheTTL returns how long LLM responses of a stage are cached; 0 means
+// not at all.
+func (c *Config) CacheTTL(stage string) time.Duration {
+	if !c.LLMCache {
+		return 0
+	}
+	if ttl, ok := c.LLMCacheStageTTLs[stage]; ok {
+		return ttl
+	}
+	return c.LLMCacheTTL
+}
+
+// ModelChain returns model followed by its fallbacks.
+func (c *Config) ModelChain(model string) []string {
+	return append([]string{model}, c.ModelFallbacks[model]...)
+}
+
+// HistoryBudget returns how many tokens of earlier turns model is given.
+func (c *Config) HistoryBudget(model string) int {
+	if budget, ok := c.HistoryTokenBudgets[model]; ok {
+		return budget
+	}
+	return c.HistoryTokenBudget
+}
+
+// ModelAllowed reports whether requests may select model.
+func (c *Config) ModelAllowed(model string) bool {
+	return slices.Contains(c.AllowedLLMModels, model)
+}
+
+// envFloat parses the named variable, treating unset or malformed values as 0.
+func envFloat(name string) float64 {
+	v, err := strconv.ParseFloat(strings.TrimSpace(os.Getenv(name)), 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// envInt parses the named variable, treating unset or malformed values as 0.
+func envInt(name string) int {
+	v, err := strconv.Atoi(strings.TrimSpace(os.Getenv(name)))
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// envDuration parses the named variable as a time.Duration such as "10m",
+// returning def when it is unset or malformed.
+func envDuration(name string, def time.Duration) time.Duration {
+	v, err := time.ParseDuration(strings.TrimSpace(os.Getenv(name)))
+	if err != nil || v < 0 {
+		return def
+	}
+	return v
+}
@@ -1,11 +1,140 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
 
+	"agios/internal/config"
+	"agios/internal/models"
+	"agios/internal/repositories"
+	"agios/internal/services"
+	"agios/internal/utils/constant"
+	"agios/internal/utils/helpers"
+	"agios/internal/utils/sse"
+
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
 )
 
-func AddMessageToThread(c echo.Context) error {
-	return c.String(http.StatusOK, "AddMessageToThread Placeholder")
+type AddMessageRequest struct {
+	QueryText string   `json:"query_text"`
+	FileIDs   []string `json:"file_ids"`
+	Model     string   `json:"model"`
+}
+
+// @Summary Add a message to a thread
+// @Description Add a follow-up message to an existing thread and stream the answer as server-sent events
+// @Tags Threads
+// @Accept json
+// @Produce text/event-stream
+// @Param threadId path string true "Thread ID"
+// @Param request body AddMessageRequest true "Follow-up query"
+// @Param X-LLM-Cache header string false "bypass or refresh the LLM response cache"
+// @Success 200 {string} string "SSE stream of START, PLAN, WEB_RESULTS, MARKDOWN_ANSWER, WIDGET and END events"
+// @Failure 400 {object} helpers.ErrorResponse "Invalid request"
+// @Failure 404 {object} helpers.ErrorResponse "Thread not found"
+// @Failure 429 {object} helpers.ErrorResponse "LLM spend budget exhausted"
+// @Failure 500 {object} helpers.ErrorResponse "Internal server error"
+// @Router /api/v1/threads/{threadId}/messages [post]
+func AddMessageToThreadHandler(threadRepo repositories.ThreadRepository, messageRepo repositories.MessageRepository, fileRepo repositories.FileRepository, generationService services.GenerationService, budgetService services.BudgetService, cfg *config.Config) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		threadID, err := uuid.Parse(c.Param("threadId"))
+		if err != nil {
+			return helpers.JSONError(c, http.StatusBadRequest, "Invalid thread ID format.", "INVALID_THREAD_ID")
+		}
+
+		req := new(AddMessageRequest)
+
+		if err := c.Bind(req); err != nil {
+			return helpers.JSONError(c, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		}
+
+		if req.QueryText == "" {
+			return helpers.JSONError(c, http.StatusBadRequest, "query_text cannot be blank", "QUERY_TEXT_BLANK")
+		}
+
+		if len(req.FileIDs) > 5 {
+			return helpers.JSONError(c, http.StatusBadRequest, "Maximum 5 file_ids allowed", "MAX_FILE_COUNT_EXCEEDED")
+		}
+
+		if helpers.WordCount(req.QueryText) > 1000 {
+			return helpers.JSONError(c, http.StatusBadRequest, "Query text exceeds the 1000-word limit.", "QUERY_TEXT_TOO_LONG")
+		}
+
+		if !validFileIDs(req.FileIDs) {
+			return helpers.JSONError(c, http.StatusBadRequest, "Invalid file ID format.", "INVALID_FILE_ID")
+		}
+
+		thread, err := threadRepo.GetThread(c.Request().Context(), threadID)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return helpers.JSONError(c, http.StatusNotFound, "Thread not found.", "THREAD_NOT_FOUND")
+			}
+			return helpers.JSONError(c, http.StatusInternalServerError, "Failed to retrieve thread.", "INTERNAL_ERROR")
+		}
+
+		if req.Model != "" && !cfg.ModelAllowed(req.Model) {
+			return helpers.JSONError(c, http.StatusBadRequest, "Model is not available.", "MODEL_NOT_ALLOWED")
+		}
+
+		// An empty model routes each stage to its configured model unless
+		// the budget downgrades the whole request.
+		model, err := budgetService.Admit(c.Request().Context(), req.Model)
+		if err != nil {
+			return budgetError(c, err)
+		}
+
+		message := &models.Message{
+			ThreadID:     thread.ID,
+			QueryText:    &req.QueryText,
+			Model:        answerModel(cfg, model),
+			StreamStatus: helpers.StringPtr(constant.StreamStatusInProgress),
+			EventType:    helpers.StringPtr(constant.EventStart),
+			MetaData:     datatypes.JSON([]byte("{}")),
+		}
+
+		if err := attachFiles(c, fileRepo, message, req.FileIDs); err != nil {
+			return fileError(c, err)
+		}
+
+		if err := messageRepo.CreateNextMessage(c.Request().Context(), message); err != nil {
+			c.Logger().Errorf("Failed to create message in thread %s: %v", thread.ID, err)
+			return helpers.JSONError(c, http.StatusInternalServerError, "Database error creating message", "MESSAGE_CREATION_FAILED")
+		}
+
+		// Only the earlier messages themselves are given to the model, not
+		// their versions or events.
+		history, err := messageRepo.GetThreadHistory(c.Request().Context(), thread.ID, message.MessageIndex)
+		if err != nil {
+			c.Logger().Errorf("Failed to load history of thread %s: %v", thread.ID, err)
+			if err := messageRepo.DeleteMessage(context.WithoutCancel(c.Request().Context()), message.ID); err != nil {
+				c.Logger().Errorf("Failed to remove message %s: %v", message.ID, err)
+			}
+			return helpers.JSONError(c, http.StatusInternalServerError, "Failed to retrieve thread.", "INTERNAL_ERROR")
+		}
+
+		sse, err := sse.SetupSSE(c)
+		if err != nil {
+			return err
+		}
+		defer sse.Close()
+
+		// Generation runs in the background and survives a dropped connection
+		// for a grace period; this request only follows its event stream.
+		generationService.Start(services.AnswerRequest{
+			Message:   message,
+			History:   history,
+			ClientIP:  c.RealIP(),
+			Model:     model,
+			CacheMode: cacheMode(c),
+		})
+
+		if err := generationService.Stream(sse.Context(), message.ID, 0, sse); err != nil {
+			c.Logger().Errorf("Failed to stream events of message %s: %v", message.ID, err)
+		}
+
+		return nil
+	}
 }
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"agios/internal/config"
+	"agios/internal/services"
+	"agios/internal/utils/constant"
+	"agios/internal/utils/helpers"
+	"agios/internal/utils/llm"
+
+	"github.com/labstack/echo/v4"
+)
+
+// budgetError reports a failed BudgetService.Admit.
+func budgetError(c echo.Context, err error) error {
+	if errors.Is(err, services.ErrBudgetExceeded) {
+		return helpers.JSONError(c, http.StatusTooManyRequests, "The LLM spend budget is exhausted. Try again later.", "BUDGET_EXCEEDED")
+	}
+	if errors.Is(err, services.ErrModelNotPriced) {
+		c.Logger().Errorf("Refusing unbudgetable model: %v", err)
+		return helpers.JSONError(c, http.StatusBadRequest, "The model has no listed price, so its spend cannot be counted against the budget.", "MODEL_NOT_PRICED")
+	}
+	c.Logger().Errorf("Failed to check LLM budget: %v", err)
+	return helpers.JSONError(c, http.StatusInternalServerError, "Failed to check usage budget.", "INTERNAL_ERROR")
+}
+
+// answerModel is the model stored on a new message until the pipeline
+// records the one that actually wrote the answer: the request's model if it
+// has one, else the model routed to synthesis.
+func answerModel(cfg *config.Config, model string) string {
+	if model != "" {
+		return model
+	}
+	return cfg.ModelForStage(constant.StageSynthesis)
+}
+
+// cacheMode reads the X-LLM-Cache header: "bypass" skips the LLM response
+// cache, "refresh" skips reading it but stores the new responses.
+func cacheMode(c echo.Context) llm.CacheMode {
+	return llm.ParseCacheMode(c.Request().Header.Get("X-LLM-Cache"))
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"agios/internal/services"
+	"agios/internal/utils/helpers"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type CancelMessageRequest struct {
+	Reason string `json:"reason"`
+}
+
+// @Summary Cancel a message's generation
+// @Description Stop generating the answer of a message and mark it FAILED with a cancellation reason
+// @Tags Messages
+// @Accept json
+// @Produce json
+// @Param messageId path string true "Message ID"
+// @Param request body CancelMessageRequest false "Optional cancellation reason"
+// @Success 200 {object} object{id=string,stream_status=string,meta_data=string} "Cancelled message"
+// @Failure 400 {object} helpers.ErrorResponse "Invalid message ID format"
+// @Failure 404 {object} helpers.ErrorResponse "Message not found"
+// @Failure 409 {object} helpers.ErrorResponse "Message is not in progress"
+// @Failure 500 {object} helpers.ErrorResponse "Internal server error"
+// @Router /api/v1/messages/{messageId}/cancel [post]
+func CancelMessageHandler(generationService services.GenerationService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		messageID, err := uuid.Parse(c.Param("messageId"))
+		if err != nil {
+			return helpers.JSONError(c, http.StatusBadRequest, "Invalid message ID format", "INVALID_MESSAGE_ID")
+		}
+
+		req := new(CancelMessageRequest)
+		if c.Request().ContentLength > 0 {
+			if err := c.Bind(req); err != nil {
+				return helpers.JSONError(c, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+			}
+		}
+
+		message, err := generationService.Cancel(c.Request().Context(), messageID, req.Reason)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return helpers.JSONError(c, http.StatusNotFound, "Message not found.", "MESSAGE_NOT_FOUND")
+			}
+			if errors.Is(err, services.ErrMessageNotInProgress) {
+				return helpers.JSONError(c, http.StatusConflict, "Message is not being generated.", "MESSAGE_NOT_IN_PROGRESS")
+			}
+
+			c.Logger().Errorf("Error cancelling message %s: %v", messageID, err)
+
+			return helpers.JSONError(c, http.StatusInternalServerError, "Failed to cancel message.", "INTERNAL_ERROR")
+		}
+
+		return c.JSON(http.StatusOK, echo.Map{
+			"id":            message.ID,
+			"stream_status": message.StreamStatus,
+			"meta_data":     message.MetaData,
+		})
+	}
+}
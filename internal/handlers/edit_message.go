@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"agios/internal/config"
+	"agios/internal/models"
+	"agios/internal/repositories"
+	"agios/internal/services"
+	"agios/internal/utils/constant"
+	"agios/internal/utils/helpers"
+	"agios/internal/utils/sse"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+type EditMessageRequest struct {
+	QueryText string `json:"query_text"`
+	// FileIDs replaces the files of the edited message; omit it to keep them.
+	FileIDs []string `json:"file_ids"`
+}
+
+// @Summary Edit a past query
+// @Description Fork the message's thread into a new branch that keeps the earlier messages, answer the edited query there and stream the answer. The new branch becomes the active one.
+// @Tags Messages
+// @Accept json
+// @Produce text/event-stream
+// @Param messageId path string true "Message ID"
+// @Param request body EditMessageRequest true "Edited query"
+// @Param X-LLM-Cache header string false "bypass or refresh the LLM response cache"
+// @Success 200 {string} string "SSE stream; START carries the thread_id of the new branch"
+// @Failure 400 {object} helpers.ErrorResponse "Invalid request"
+// @Failure 404 {object} helpers.ErrorResponse "Message not found"
+// @Failure 429 {object} helpers.ErrorResponse "LLM spend budget exhausted"
+// @Failure 500 {object} helpers.ErrorResponse "Internal server error"
+// @Router /api/v1/messages/{messageId}/edit [post]
+func EditMessageHandler(threadRepo repositories.ThreadRepository, messageRepo repositories.MessageRepository, fileRepo repositories.FileRepository, generationService services.GenerationService, budgetService services.BudgetService, cfg *config.Config) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		messageID, err := uuid.Parse(c.Param("messageId"))
+		if err != nil {
+			return helpers.JSONError(c, http.StatusBadRequest, "Invalid message ID format", "INVALID_MESSAGE_ID")
+		}
+
+		req := new(EditMessageRequest)
+
+		if err := c.Bind(req); err != nil {
+			return helpers.JSONError(c, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		}
+
+		if req.QueryText == "" {
+			return helpers.JSONError(c, http.StatusBadRequest, "query_text cannot be blank", "QUERY_TEXT_BLANK")
+		}
+
+		if len(req.FileIDs) > 5 {
+			return helpers.JSONError(c, http.StatusBadRequest, "Maximum 5 file_ids allowed", "MAX_FILE_COUNT_EXCEEDED")
+		}
+
+		if helpers.WordCount(req.QueryText) > 1000 {
+			return helpers.JSONError(c, http.StatusBadRequest, "Query text exceeds the 1000-word limit.", "QUERY_TEXT_TOO_LONG")
+		}
+
+		if !validFileIDs(req.FileIDs) {
+			return helpers.JSONError(c, http.StatusBadRequest, "Invalid file ID format.", "INVALID_FILE_ID")
+		}
+
+		original, err := messageRepo.GetMessage(c.Request().Context(), messageID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return helpers.JSONError(c, http.StatusNotFound, "Message not found.", "MESSAGE_NOT_FOUND")
+			}
+			return helpers.JSONError(c, http.StatusInternalServerError, "Failed to retrieve message.", "INTERNAL_ERROR")
+		}
+
+		model, err := budgetService.Admit(c.Request().Context(), "")
+		if err != nil {
+			return budgetError(c, err)
+		}
+
+		source, err := threadRepo.GetThread(c.Request().Context(), original.ThreadID)
+		if err != nil {
+			return helpers.JSONError(c, http.StatusInternalServerError, "Failed to retrieve thread.", "INTERNAL_ERROR")
+		}
+
+		edited := &models.Message{
+			QueryText:    &req.QueryText,
+			MessageIndex: original.MessageIndex,
+			Model:        answerModel(cfg, model),
+			StreamStatus: helpers.StringPtr(constant.StreamStatusInProgress),
+			EventType:    helpers.StringPtr(constant.EventStart),
+			MetaData:     datatypes.JSON([]byte("{}")),
+		}
+
+		if req.FileIDs != nil {
+			if err := attachFiles(c, fileRepo, edited, req.FileIDs); err != nil {
+				return fileError(c, err)
+			}
+		} else if len(original.Files) > 0 {
+			edited.Files = original.Files
+			ids := make([]string, 0, len(original.Files))
+			for _, f := range original.Files {
+				ids = append(ids, f.ID.String())
+			}
+			metaData, _ := json.Marshal(map[string]any{"file_ids": ids})
+			edited.MetaData = datatypes.JSON(metaData)
+		}
+
+		branch := &models.Thread{
+			Slug: helpers.Slugify(helpers.GetFirstNWords(req.QueryText, 5)) + "-" + uuid.NewString()[:8],
+		}
+
+		if err := threadRepo.ForkThread(c.Request().Context(), source, branch, edited); err != nil {
+			c.Logger().Errorf("Failed to fork thread %s at message %d: %v", source.ID, original.MessageIndex, err)
+			return helpers.JSONError(c, http.StatusInternalServerError, "Database error creating branch", "BRANCH_CREATION_FAILED")
+		}
+
+		sse, err := sse.SetupSSE(c)
+		if err != nil {
+			return err
+		}
+		defer sse.Close()
+
+		generationService.Start(services.AnswerRequest{
+			Message:   edited,
+			History:   branch.Messages,
+			ClientIP:  c.RealIP(),
+			Model:     model,
+			CacheMode: cacheMode(c),
+		})
+
+		if err := generationService.Stream(sse.Context(), edited.ID, 0, sse); err != nil {
+			c.Logger().Errorf("Failed to stream events of message %s: %v", edited.ID, err)
+		}
+
+		return nil
+	}
+}
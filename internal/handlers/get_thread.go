@@ -21,7 +21,7 @@ type ThreadHandler struct {
 // @Accept json
 // @Produce json
 // @Param threadId path string true "Thread ID"
-// @Success 200 {object} object{id=string,slug=string,created_at=string,updated_at=string,version=int,messages=[]object{id=string,query_text=string,response_text=string,event_type=string,stream_status=string,meta_data=string,message_index=int,created_at=string,version=int}} "Thread details with messages"
+// @Success 200 {object} object{id=string,slug=string,created_at=string,updated_at=string,version=int,parent_thread_id=string,root_thread_id=string,fork_message_index=int,messages=[]object{id=string,query_text=string,response_text=string,event_type=string,stream_status=string,meta_data=string,message_index=int,created_at=string,version=int,model=string,input_token=int,output_token=int,response_time=number,cost=number,selected_version=int,events=[]object{sequence=int,event=string,data=object,created_at=string},versions=[]object{version_index=int,selected=bool,response_text=string,event_type=string,stream_status=string,model=string,input_token=int,output_token=int,response_time=number,cost=number,meta_data=string,created_at=string,events=[]object{sequence=int,event=string,data=object,created_at=string}}}} "Thread details with messages"
 // @Failure 400 {object} helpers.ErrorResponse "Invalid thread ID format"
 // @Failure 404 {object} helpers.ErrorResponse "Thread not found"
 // @Failure 500 {object} helpers.ErrorResponse "Internal server error"
@@ -42,25 +42,72 @@ func (h *ThreadHandler) GetThread(c echo.Context) error {
 	}
 
 	resp := echo.Map{
-		"id":         thread.ID,
-		"slug":       thread.Slug,
-		"created_at": thread.CreatedAt,
-		"updated_at": thread.UpdatedAt,
-		"version":    thread.Version,
-		"messages":   []echo.Map{},
+		"id":                 thread.ID,
+		"slug":               thread.Slug,
+		"created_at":         thread.CreatedAt,
+		"updated_at":         thread.UpdatedAt,
+		"version":            thread.Version,
+		"messages":           []echo.Map{},
+		"parent_thread_id":   thread.ParentThreadID,
+		"root_thread_id":     thread.RootThreadID,
+		"fork_message_index": thread.ForkMessageIndex,
 	}
 
 	for _, m := range thread.Messages {
+		// Events replay each answer exactly as it was streamed.
+		events := map[int][]echo.Map{}
+		for _, e := range m.Events {
+			events[e.VersionIndex] = append(events[e.VersionIndex], echo.Map{
+				"sequence":   e.Sequence,
+				"event":      e.EventType,
+				"data":       e.Payload,
+				"created_at": e.CreatedAt,
+			})
+		}
+		eventsOf := func(version int) []echo.Map {
+			if e, ok := events[version]; ok {
+				return e
+			}
+			return []echo.Map{}
+		}
+
+		versions := []echo.Map{}
+		for _, v := range m.AnswerVersions {
+			versions = append(versions, echo.Map{
+				"version_index": v.VersionIndex,
+				"selected":      v.VersionIndex == m.SelectedVersion,
+				"response_text": v.ResponseText,
+				"event_type":    v.EventType,
+				"stream_status": v.StreamStatus,
+				"model":         v.Model,
+				"input_token":   v.InputToken,
+				"output_token":  v.OutputToken,
+				"response_time": v.ResponseTime,
+				"cost":          v.Cost,
+				"meta_data":     v.MetaData,
+				"created_at":    v.CreatedAt,
+				"events":        eventsOf(v.VersionIndex),
+			})
+		}
+
 		resp["messages"] = append(resp["messages"].([]echo.Map), echo.Map{
-			"id":            m.ID,
-			"query_text":    m.QueryText,
-			"response_text": m.ResponseText,
-			"event_type":    m.EventType,
-			"stream_status": m.StreamStatus,
-			"meta_data":     m.MetaData,
-			"message_index": m.MessageIndex,
-			"created_at":    m.CreatedAt,
-			"version":       m.Version,
+			"id":               m.ID,
+			"query_text":       m.QueryText,
+			"response_text":    m.ResponseText,
+			"event_type":       m.EventType,
+			"stream_status":    m.StreamStatus,
+			"meta_data":        m.MetaData,
+			"message_index":    m.MessageIndex,
+			"created_at":       m.CreatedAt,
+			"version":          m.Version,
+			"model":            m.Model,
+			"input_token":      m.InputToken,
+			"output_token":     m.OutputToken,
+			"response_time":    m.ResponseTime,
+			"cost":             m.Cost,
+			"selected_version": m.SelectedVersion,
+			"versions":         versions,
+			"events":           eventsOf(m.SelectedVersion),
 		})
 	}
 
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"agios/internal/repositories"
+	"agios/internal/services"
+	"agios/internal/utils/helpers"
+	"agios/internal/utils/llm"
+
+	"github.com/labstack/echo/v4"
+)
+
+// @Summary Report LLM usage
+// @Description Tokens, cost and request counts of the LLM calls in a date range, grouped by any of day, model, tool, stage and user, with the current budget status
+// @Tags Usage
+// @Produce json
+// @Param group_by query string false "Comma-separated dimensions: day, model, tool, stage, user" default(day)
+// @Param from query string false "First day included (YYYY-MM-DD, UTC); defaults to 30 days ago"
+// @Param to query string false "Last day included (YYYY-MM-DD, UTC); defaults to today"
+// @Success 200 {object} object{from=string,to=string,group_by=[]string,rows=[]object{day=string,model=string,tool=string,stage=string,user=string,input_tokens=int,output_tokens=int,cost_usd=number,requests=int,calls=int},budget=object} "Usage report"
+// @Failure 400 {object} helpers.ErrorResponse "Invalid query"
+// @Failure 500 {object} helpers.ErrorResponse "Internal server error"
+// @Router /api/v1/usage [get]
+func GetUsageHandler(usageRepo repositories.UsageRepository, budgetService services.BudgetService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		groupBy := []string{repositories.UsageByDay}
+		if v := c.QueryParam("group_by"); v != "" {
+			groupBy = groupBy[:0]
+			for _, dim := range strings.Split(v, ",") {
+				dim = strings.TrimSpace(dim)
+				if !repositories.ValidUsageDimension(dim) {
+					return helpers.JSONError(c, http.StatusBadRequest, "group_by accepts day, model, tool, stage and user.", "INVALID_GROUP_BY")
+				}
+				groupBy = append(groupBy, dim)
+			}
+		}
+
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		from := today.AddDate(0, 0, -30)
+		to := today
+		var err error
+		if v := c.QueryParam("from"); v != "" {
+			if from, err = time.Parse(time.DateOnly, v); err != nil {
+				return helpers.JSONError(c, http.StatusBadRequest, "from must be a date like 2025-06-20.", "INVALID_DATE")
+			}
+		}
+		if v := c.QueryParam("to"); v != "" {
+			if to, err = time.Parse(time.DateOnly, v); err != nil {
+				return helpers.JSONError(c, http.StatusBadRequest, "to must be a date like 2025-06-20.", "INVALID_DATE")
+			}
+		}
+		if to.Before(from) {
+			return helpers.JSONError(c, http.StatusBadRequest, "to must not be before from.", "INVALID_DATE")
+		}
+
+		rows, err := usageRepo.Summarize(c.Request().Context(), repositories.UsageQuery{
+			From:    from,
+			To:      to.AddDate(0, 0, 1),
+			GroupBy: groupBy,
+		})
+		if err != nil {
+			c.Logger().Errorf("Failed to summarize usage: %v", err)
+			return helpers.JSONError(c, http.StatusInternalServerError, "Failed to retrieve usage.", "INTERNAL_ERROR")
+		}
+
+		budget, err := budgetService.Status(c.Request().Context())
+		if err != nil {
+			c.Logger().Errorf("Failed to read budget status: %v", err)
+			return helpers.JSONError(c, http.StatusInternalServerError, "Failed to retrieve usage.", "INTERNAL_ERROR")
+		}
+
+		cache, err := llm.CacheStats(c.Request().Context())
+		if err != nil {
+			c.Logger().Errorf("Failed to read LLM cache stats: %v", err)
+		}
+
+		out := []echo.Map{}
+		for _, r := range rows {
+			row := echo.Map{
+				"input_tokens":  r.InputTokens,
+				"output_tokens": r.OutputTokens,
+				"cost_usd":      r.Cost,
+				"requests":      r.Requests,
+				"calls":         r.Calls,
+			}
+			dims := map[string]string{
+				repositories.UsageByDay:   r.Day,
+				repositories.UsageByModel: r.Model,
+				repositories.UsageByTool:  r.Tool,
+				repositories.UsageByStage: r.Stage,
+				repositories.UsageByUser:  r.UserID,
+			}
+			for _, dim := range groupBy {
+				row[dim] = dims[dim]
+			}
+			out = append(out, row)
+		}
+
+		return c.JSON(http.StatusOK, echo.Map{
+			"from":     from.Format(time.DateOnly),
+			"to":       to.Format(time.DateOnly),
+			"group_by": groupBy,
+			"rows":     out,
+			"budget":   budget,
+			"cache":    cache,
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"agios/internal/models"
+	"agios/internal/repositories"
+	"agios/internal/utils/helpers"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/datatypes"
+)
+
+// validFileIDs reports whether every id is a well-formed UUID.
+func validFileIDs(fileIDs []string) bool {
+	for _, id := range fileIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// unknownFilesError lists requested file IDs that match no stored file.
+type unknownFilesError struct {
+	ids []string
+}
+
+func (e *unknownFilesError) Error() string {
+	return "unknown file IDs: " + strings.Join(e.ids, ", ")
+}
+
+// attachFiles loads the requested files onto message and records their IDs
+// in its metadata. IDs that do not match a stored file fail with an
+// *unknownFilesError.
+func attachFiles(c echo.Context, fileRepo repositories.FileRepository, message *models.Message, fileIDs []string) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	files, err := fileRepo.GetFilesByIDs(c.Request().Context(), fileIDs)
+	if err != nil {
+		return err
+	}
+
+	found := map[uuid.UUID]bool{}
+	ids := make([]string, 0, len(files))
+	for _, f := range files {
+		found[f.ID] = true
+		ids = append(ids, f.ID.String())
+	}
+	var unknown []string
+	for _, id := range fileIDs {
+		if !found[uuid.MustParse(id)] {
+			unknown = append(unknown, id)
+		}
+	}
+	if len(unknown) > 0 {
+		return &unknownFilesError{ids: unknown}
+	}
+
+	message.Files = files
+	metaData, _ := json.Marshal(map[string]any{"file_ids": ids})
+	message.MetaData = datatypes.JSON(metaData)
+
+	return nil
+}
+
+// fileError responds to a failed attachFiles.
+func fileError(c echo.Context, err error) error {
+	var unknown *unknownFilesError
+	if errors.As(err, &unknown) {
+		return helpers.JSONError(c, http.StatusBadRequest, "Unknown file IDs: "+strings.Join(unknown.ids, ", ")+".", "FILE_NOT_FOUND")
+	}
+	return helpers.JSONError(c, http.StatusInternalServerError, "Database error retrieving files", "FILE_RETRIEVAL_FAILED")
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"agios/internal/config"
+	"agios/internal/services"
+	"agios/internal/utils/helpers"
+	"agios/internal/utils/llm"
+	"agios/internal/utils/sse"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type RegenerateMessageRequest struct {
+	Model string `json:"model"`
+}
+
+// @Summary Regenerate a message's answer
+// @Description Re-run the pipeline for the same query and files, optionally with another model, and stream the new answer. Earlier answers are kept as versions.
+// @Tags Messages
+// @Accept json
+// @Produce text/event-stream
+// @Param messageId path string true "Message ID"
+// @Param request body RegenerateMessageRequest false "Optional model override"
+// @Param X-LLM-Cache header string false "bypass the LLM response cache; regeneration refreshes it by default"
+// @Success 200 {string} string "SSE stream of START, PLAN, WEB_RESULTS, MARKDOWN_ANSWER, WIDGET and END events"
+// @Failure 400 {object} helpers.ErrorResponse "Invalid request"
+// @Failure 404 {object} helpers.ErrorResponse "Message not found"
+// @Failure 409 {object} helpers.ErrorResponse "Message is still being generated"
+// @Failure 429 {object} helpers.ErrorResponse "LLM spend budget exhausted"
+// @Failure 500 {object} helpers.ErrorResponse "Internal server error"
+// @Router /api/v1/messages/{messageId}/regenerate [post]
+func RegenerateMessageHandler(generationService services.GenerationService, budgetService services.BudgetService, cfg *config.Config) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		messageID, err := uuid.Parse(c.Param("messageId"))
+		if err != nil {
+			return helpers.JSONError(c, http.StatusBadRequest, "Invalid message ID format", "INVALID_MESSAGE_ID")
+		}
+
+		req := new(RegenerateMessageRequest)
+		if c.Request().ContentLength > 0 {
+			if err := c.Bind(req); err != nil {
+				return helpers.JSONError(c, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+			}
+		}
+
+		if req.Model != "" {
+			if !cfg.ModelAllowed(req.Model) {
+				return helpers.JSONError(c, http.StatusBadRequest, "Model is not available.", "MODEL_NOT_ALLOWED")
+			}
+		}
+
+		// An empty model routes each stage to its configured model unless
+		// the budget downgrades the whole request.
+		model, err := budgetService.Admit(c.Request().Context(), req.Model)
+		if err != nil {
+			return budgetError(c, err)
+		}
+
+		// A regenerated answer should differ, so cached responses are only
+		// replaced unless the caller bypasses the cache entirely.
+		mode := cacheMode(c)
+		if mode == llm.CacheDefault {
+			mode = llm.CacheRefresh
+		}
+
+		message, err := generationService.Regenerate(c.Request().Context(), messageID, model, c.RealIP(), mode)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return helpers.JSONError(c, http.StatusNotFound, "Message not found.", "MESSAGE_NOT_FOUND")
+			}
+			if errors.Is(err, services.ErrMessageInProgress) {
+				return helpers.JSONError(c, http.StatusConflict, "Message is still being generated.", "MESSAGE_IN_PROGRESS")
+			}
+
+			c.Logger().Errorf("Error regenerating message %s: %v", messageID, err)
+
+			return helpers.JSONError(c, http.StatusInternalServerError, "Failed to regenerate message.", "INTERNAL_ERROR")
+		}
+
+		sse, err := sse.SetupSSE(c)
+		if err != nil {
+			return err
+		}
+		defer sse.Close()
+
+		if err := generationService.Stream(sse.Context(), message.ID, 0, sse); err != nil {
+			c.Logger().Errorf("Failed to stream events of message %s: %v", message.ID, err)
+		}
+
+		return nil
+	}
+}
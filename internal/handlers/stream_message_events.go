@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"agios/internal/services"
+	"agios/internal/utils/helpers"
+	"agios/internal/utils/sse"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// @Summary Resume a message's event stream
+// @Description Replay the events of a message after Last-Event-ID, then follow the live stream until END
+// @Tags Messages
+// @Produce text/event-stream
+// @Param messageId path string true "Message ID"
+// @Param Last-Event-ID header int false "ID of the last event the client received"
+// @Param last_event_id query int false "Alternative to the Last-Event-ID header"
+// @Success 200 {string} string "SSE stream of the remaining events"
+// @Failure 400 {object} helpers.ErrorResponse "Invalid message ID or event ID"
+// @Failure 404 {object} helpers.ErrorResponse "Event stream not found or expired"
+// @Failure 500 {object} helpers.ErrorResponse "Internal server error"
+// @Router /api/v1/messages/{messageId}/events [get]
+func StreamMessageEventsHandler(generationService services.GenerationService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		messageID, err := uuid.Parse(c.Param("messageId"))
+		if err != nil {
+			return helpers.JSONError(c, http.StatusBadRequest, "Invalid message ID format", "INVALID_MESSAGE_ID")
+		}
+
+		lastEventIDStr := c.Request().Header.Get("Last-Event-ID")
+		if lastEventIDStr == "" {
+			lastEventIDStr = c.QueryParam("last_event_id")
+		}
+
+		var lastEventID int64
+		if lastEventIDStr != "" {
+			lastEventID, err = strconv.ParseInt(lastEventIDStr, 10, 64)
+			if err != nil || lastEventID < 0 {
+				return helpers.JSONError(c, http.StatusBadRequest, "Invalid Last-Event-ID", "INVALID_LAST_EVENT_ID")
+			}
+		}
+
+		ok, err := generationService.Resumable(c.Request().Context(), messageID)
+		if err != nil {
+			c.Logger().Errorf("Failed to look up event stream of message %s: %v", messageID, err)
+			return helpers.JSONError(c, http.StatusInternalServerError, "Failed to read event stream.", "INTERNAL_ERROR")
+		}
+		if !ok {
+			return helpers.JSONError(c, http.StatusNotFound, "Event stream not found or expired.", "STREAM_NOT_FOUND")
+		}
+
+		sse, err := sse.SetupSSE(c)
+		if err != nil {
+			return err
+		}
+		defer sse.Close()
+
+		if err := generationService.Stream(sse.Context(), messageID, lastEventID, sse); err != nil {
+			c.Logger().Errorf("Failed to stream events of message %s: %v", messageID, err)
+		}
+
+		return nil
+	}
+}
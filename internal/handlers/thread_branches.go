@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"agios/internal/models"
+	"agios/internal/repositories"
+	"agios/internal/utils/helpers"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type SwitchBranchRequest struct {
+	BranchID string `json:"branch_id"`
+}
+
+// branchesResponse lists the branch family of thread.
+func branchesResponse(c echo.Context, threadRepo repositories.ThreadRepository, thread *models.Thread) error {
+	rootID := thread.ID
+	if thread.RootThreadID != nil {
+		rootID = *thread.RootThreadID
+	}
+
+	threads, err := threadRepo.ListBranches(c.Request().Context(), rootID)
+	if err != nil {
+		return helpers.JSONError(c, http.StatusInternalServerError, "Failed to retrieve branches.", "INTERNAL_ERROR")
+	}
+
+	var activeID *uuid.UUID
+	for _, t := range threads {
+		if t.ID == rootID {
+			activeID = t.ActiveBranchID
+			if activeID == nil {
+				activeID = &t.ID
+			}
+		}
+	}
+
+	branches := []echo.Map{}
+	for _, t := range threads {
+		branches = append(branches, echo.Map{
+			"id":                 t.ID,
+			"slug":               t.Slug,
+			"parent_thread_id":   t.ParentThreadID,
+			"fork_message_index": t.ForkMessageIndex,
+			"created_at":         t.CreatedAt,
+			"active":             activeID != nil && *activeID == t.ID,
+		})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"root_thread_id":   rootID,
+		"active_branch_id": activeID,
+		"branches":         branches,
+	})
+}
+
+// @Summary List the branches of a thread
+// @Description List the root thread and every branch forked from it by editing a message, with the active branch marked
+// @Tags Threads
+// @Produce json
+// @Param threadId path string true "Thread ID of any branch in the family"
+// @Success 200 {object} object{root_thread_id=string,active_branch_id=string,branches=[]object{id=string,slug=string,parent_thread_id=string,fork_message_index=int,created_at=string,active=bool}} "Branches"
+// @Failure 400 {object} helpers.ErrorResponse "Invalid thread ID format"
+// @Failure 404 {object} helpers.ErrorResponse "Thread not found"
+// @Failure 500 {object} helpers.ErrorResponse "Internal server error"
+// @Router /api/v1/threads/{threadId}/branches [get]
+func ListBranchesHandler(threadRepo repositories.ThreadRepository) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		threadID, err := uuid.Parse(c.Param("threadId"))
+		if err != nil {
+			return helpers.JSONError(c, http.StatusBadRequest, "Invalid thread ID format.", "INVALID_THREAD_ID")
+		}
+
+		thread, err := threadRepo.GetThread(c.Request().Context(), threadID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return helpers.JSONError(c, http.StatusNotFound, "Thread not found.", "THREAD_NOT_FOUND")
+			}
+			return helpers.JSONError(c, http.StatusInternalServerError, "Failed to retrieve thread.", "INTERNAL_ERROR")
+		}
+
+		return branchesResponse(c, threadRepo, thread)
+	}
+}
+
+// @Summary Switch the active branch
+// @Description Make another branch of the thread's family the active one
+// @Tags Threads
+// @Accept json
+// @Produce json
+// @Param threadId path string true "Thread ID of any branch in the family"
+// @Param request body SwitchBranchRequest true "Branch to switch to"
+// @Success 200 {object} object{root_thread_id=string,active_branch_id=string,branches=[]object{id=string,slug=string,parent_thread_id=string,fork_message_index=int,created_at=string,active=bool}} "Branches"
+// @Failure 400 {object} helpers.ErrorResponse "Invalid request"
+// @Failure 404 {object} helpers.ErrorResponse "Thread or branch not found"
+// @Failure 500 {object} helpers.ErrorResponse "Internal server error"
+// @Router /api/v1/threads/{threadId}/active_branch [put]
+func SwitchBranchHandler(threadRepo repositories.ThreadRepository) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		threadID, err := uuid.Parse(c.Param("threadId"))
+		if err != nil {
+			return helpers.JSONError(c, http.StatusBadRequest, "Invalid thread ID format.", "INVALID_THREAD_ID")
+		}
+
+		req := new(SwitchBranchRequest)
+
+		if err := c.Bind(req); err != nil {
+			return helpers.JSONError(c, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		}
+
+		branchID, err := uuid.Parse(req.BranchID)
+		if err != nil {
+			return helpers.JSONError(c, http.StatusBadRequest, "Invalid branch ID format.", "INVALID_BRANCH_ID")
+		}
+
+		thread, err := threadRepo.GetThread(c.Request().Context(), threadID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return helpers.JSONError(c, http.StatusNotFound, "Thread not found.", "THREAD_NOT_FOUND")
+			}
+			return helpers.JSONError(c, http.StatusInternalServerError, "Failed to retrieve thread.", "INTERNAL_ERROR")
+		}
+
+		rootID := thread.ID
+		if thread.RootThreadID != nil {
+			rootID = *thread.RootThreadID
+		}
+
+		if err := threadRepo.SetActiveBranch(c.Request().Context(), rootID, branchID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return helpers.JSONError(c, http.StatusNotFound, "Branch not found in this thread.", "BRANCH_NOT_FOUND")
+			}
+			c.Logger().Errorf("Failed to switch thread %s to branch %s: %v", rootID, branchID, err)
+			return helpers.JSONError(c, http.StatusInternalServerError, "Failed to switch branch.", "INTERNAL_ERROR")
+		}
+
+		return branchesResponse(c, threadRepo, thread)
+	}
+}
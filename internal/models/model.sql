@@ -22,7 +22,13 @@ CREATE TABLE threads (
   user_id     UUID        NULL,                     -- future auth
   created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
   updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
-  version     TEXT        NOT NULL DEFAULT '1.0'
+  version     TEXT        NOT NULL DEFAULT '1.0',
+  parent_thread_id   UUID     NULL REFERENCES threads(id) ON DELETE SET NULL, -- thread this branch was forked from
+  root_thread_id     UUID     NULL REFERENCES threads(id) ON DELETE SET NULL, -- first thread of the branch family; NULL on the root
+  fork_message_index INTEGER  NULL,                 -- index of the edited message
+  active_branch_id   UUID     NULL REFERENCES threads(id) ON DELETE SET NULL, -- on the root: branch the user switched to
+  history_summary    TEXT     NULL,                 -- running summary of the earlier messages
+  summary_through_index INTEGER NULL               -- last message_index folded into history_summary
 );
 
 -- ========================================================
@@ -42,14 +48,70 @@ CREATE TABLE messages (
   input_token    INTEGER         NOT NULL,
   output_token   INTEGER         NOT NULL,
   response_time  DOUBLE PRECISION NOT NULL,         -- seconds
+  cost           DOUBLE PRECISION NOT NULL DEFAULT 0, -- USD, summed over the LLM calls
   stream_status  TEXT            NULL CHECK (stream_status IN ('IN_PROGRESS','DONE','FAILED')),
   message_index  INTEGER         NOT NULL,
   meta_data      JSONB           NOT NULL DEFAULT '{}'::jsonb,
   created_at     TIMESTAMPTZ     NOT NULL DEFAULT now(),
   version        TEXT            NOT NULL DEFAULT '1.0',
+  selected_version INTEGER       NOT NULL DEFAULT 0,  -- version_index of the current answer
   UNIQUE(thread_id, message_index)
 );
 
+-- ========================================================
+-- 🗄️ Table: message_versions
+-- Alternate answers of a regenerated message.
+-- ========================================================
+CREATE TABLE message_versions (
+  id             UUID            PRIMARY KEY DEFAULT gen_random_uuid(),
+  message_id     UUID            NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+  version_index  INTEGER         NOT NULL,            -- 0 is the original answer
+  response_text  TEXT            NULL,
+  event_type     TEXT            NULL,
+  model          TEXT            NOT NULL,
+  input_token    INTEGER         NOT NULL,
+  output_token   INTEGER         NOT NULL,
+  response_time  DOUBLE PRECISION NOT NULL,         -- seconds
+  cost           DOUBLE PRECISION NOT NULL DEFAULT 0, -- USD
+  stream_status  TEXT            NULL CHECK (stream_status IN ('IN_PROGRESS','DONE','FAILED')),
+  meta_data      JSONB           NOT NULL DEFAULT '{}'::jsonb,
+  created_at     TIMESTAMPTZ     NOT NULL DEFAULT now(),
+  version        TEXT            NOT NULL DEFAULT '1.0',
+  UNIQUE(message_id, version_index)
+);
+
+-- ========================================================
+-- 🗄️ Table: llm_calls
+-- Token usage, latency and cost of every LLM request.
+-- ========================================================
+CREATE TABLE llm_calls (
+  id             UUID            PRIMARY KEY DEFAULT gen_random_uuid(),
+  message_id     UUID            NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+  stage          TEXT            NOT NULL,            -- tool_detection, synthesis, ...
+  model          TEXT            NOT NULL,
+  tool           TEXT            NOT NULL DEFAULT '',
+  input_token    INTEGER         NOT NULL,
+  output_token   INTEGER         NOT NULL,
+  latency_ms     BIGINT          NOT NULL,
+  cost           DOUBLE PRECISION NOT NULL,         -- USD
+  created_at     TIMESTAMPTZ     NOT NULL DEFAULT now()
+);
+
+-- ========================================================
+-- 🗄️ Table: message_events
+-- Every SSE event streamed for a message, in order.
+-- ========================================================
+CREATE TABLE message_events (
+  id             UUID            PRIMARY KEY DEFAULT gen_random_uuid(),
+  message_id     UUID            NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+  version_index  INTEGER         NOT NULL DEFAULT 0,  -- answer version the event belongs to
+  sequence       BIGINT          NOT NULL,            -- SSE event id
+  event_type     TEXT            NOT NULL,
+  payload        JSONB           NOT NULL DEFAULT '{}'::jsonb,
+  created_at     TIMESTAMPTZ     NOT NULL DEFAULT now(),
+  UNIQUE(message_id, version_index, sequence)
+);
+
 -- ========================================================
 -- 🗄️ Table: message_files
 -- Pivot for many-to-many messages ↔ upload_files
@@ -66,6 +128,10 @@ CREATE TABLE message_files (
 CREATE INDEX idx_messages_thread        ON messages(thread_id);
 CREATE INDEX idx_messages_created       ON messages(created_at);
 CREATE INDEX idx_threads_created        ON threads(created_at);
+CREATE INDEX idx_llm_calls_message      ON llm_calls(message_id);
+CREATE INDEX idx_llm_calls_created      ON llm_calls(created_at);
+CREATE INDEX idx_threads_parent         ON threads(parent_thread_id);
+CREATE INDEX idx_threads_root           ON threads(root_thread_id);
 CREATE INDEX idx_upload_files_uploaded  ON upload_files(uploaded_at);
 
 -- ========================================================
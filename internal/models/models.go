@@ -26,6 +26,18 @@ type Thread struct {
 	UpdatedAt time.Time  `gorm:"autoUpdateTime"`
 	Version   string     `gorm:"type:text;not null;default:'1.0'"`
 	Messages  []Message  `gorm:"constraint:OnDelete:CASCADE;"`
+	// Branches: editing message N of a thread forks a new thread holding
+	// copies of messages 0..N-1 and the edited query. Every branch points at
+	// the thread it was forked from and at the root of its family.
+	ParentThreadID   *uuid.UUID `gorm:"type:uuid;index"`
+	RootThreadID     *uuid.UUID `gorm:"type:uuid;index"` // nil on the root itself
+	ForkMessageIndex *int       // index of the edited message
+	ActiveBranchID   *uuid.UUID `gorm:"type:uuid"` // set on the root only
+	// HistorySummary is the running summary of the messages up to
+	// SummaryThroughIndex, kept for threads too long for the context of
+	// the answering model.
+	HistorySummary      *string `gorm:"type:text"`
+	SummaryThroughIndex *int
 }
 
 type Message struct {
@@ -38,13 +50,64 @@ type Message struct {
 	Model        string         `gorm:"type:text;not null"`
 	InputToken   int            `gorm:"not null"`
 	OutputToken  int            `gorm:"not null"`
-	ResponseTime float64        `gorm:"not null"` // in seconds
+	ResponseTime float64        `gorm:"not null"`           // in seconds
+	Cost         float64        `gorm:"not null;default:0"` // in USD, summed over the LLM calls
 	StreamStatus *string        `gorm:"type:text;check:stream_status IN ('IN_PROGRESS','DONE','FAILED')"`
 	MessageIndex int            `gorm:"not null;index:,unique,composite:idx_thread_msgidx"`
 	MetaData     datatypes.JSON `gorm:"type:jsonb;not null;default:'{}'"`
 	CreatedAt    time.Time      `gorm:"autoCreateTime"`
 	Version      string         `gorm:"type:text;not null;default:'1.0'"`
 	Files        []*UploadFile  `gorm:"many2many:message_files;constraint:OnDelete:CASCADE;"`
+	// SelectedVersion is the VersionIndex of the answer the message currently holds.
+	SelectedVersion int              `gorm:"not null;default:0"`
+	AnswerVersions  []MessageVersion `gorm:"constraint:OnDelete:CASCADE;"`
+	Events          []MessageEvent   `gorm:"constraint:OnDelete:CASCADE;"`
+}
+
+// MessageEvent is one SSE event as it was streamed for a message, so a
+// finished answer can be re-rendered exactly. VersionIndex tells the answers
+// of a regenerated message apart.
+type MessageEvent struct {
+	ID           uuid.UUID      `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	MessageID    uuid.UUID      `gorm:"type:uuid;not null;index:,unique,composite:idx_message_event"`
+	VersionIndex int            `gorm:"not null;default:0;index:,unique,composite:idx_message_event"`
+	Sequence     int64          `gorm:"not null;index:,unique,composite:idx_message_event"`
+	EventType    string         `gorm:"type:text;not null"`
+	Payload      datatypes.JSON `gorm:"type:jsonb;not null;default:'{}'"`
+	CreatedAt    time.Time      `gorm:"not null"`
+}
+
+// MessageVersion is a stored answer of a message. A message gets versions
+// once it is regenerated; version 0 is the original answer.
+type MessageVersion struct {
+	ID           uuid.UUID      `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	MessageID    uuid.UUID      `gorm:"type:uuid;not null;index:,unique,composite:idx_message_version"`
+	VersionIndex int            `gorm:"not null;index:,unique,composite:idx_message_version"`
+	ResponseText *string        `gorm:"type:text"`
+	EventType    *string        `gorm:"type:text"`
+	Model        string         `gorm:"type:text;not null"`
+	InputToken   int            `gorm:"not null"`
+	OutputToken  int            `gorm:"not null"`
+	ResponseTime float64        `gorm:"not null"`           // in seconds
+	Cost         float64        `gorm:"not null;default:0"` // in USD
+	StreamStatus *string        `gorm:"type:text;check:stream_status IN ('IN_PROGRESS','DONE','FAILED')"`
+	MetaData     datatypes.JSON `gorm:"type:jsonb;not null;default:'{}'"`
+	CreatedAt    time.Time      `gorm:"autoCreateTime"`
+	Version      string         `gorm:"type:text;not null;default:'1.0'"`
+}
+
+// LLMCall is the usage of one LLM request made while answering a message.
+type LLMCall struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	MessageID   uuid.UUID `gorm:"type:uuid;not null;index"`
+	Stage       string    `gorm:"type:text;not null"`
+	Model       string    `gorm:"type:text;not null"`
+	Tool        string    `gorm:"type:text;not null;default:''"`
+	InputToken  int       `gorm:"not null"`
+	OutputToken int       `gorm:"not null"`
+	LatencyMS   int64     `gorm:"not null"`
+	Cost        float64   `gorm:"not null"` // in USD
+	CreatedAt   time.Time `gorm:"not null;index"`
 }
 
 type MessageFile struct {
@@ -2,7 +2,7 @@ package prompts
 
 import "github.com/tmc/langchaingo/prompts"
 
-var business_summary_prompt = prompts.PromptTemplate{
+var BusinessSummaryPrompt = prompts.PromptTemplate{
 	Template: `<goal>Your task is to provide a brief overview of the nearby places found.</goal>
     <instructions>
     - State the number of places found, perhaps by primary type if easily discernible (e.g., "Found 10 places, mostly cafes and restaurants.").
@@ -26,5 +26,6 @@ var business_summary_prompt = prompts.PromptTemplate{
     </summary_guidelines>
     Summary:
 	`,
+	TemplateFormat: prompts.TemplateFormatGoTemplate,
 	InputVariables: []string{"verbosity_instruct", "response_length_instruct", "formal_level_instruct", "creativity_instruct", "precision_instruct", "user_instruction", "business_data"},
 }
@@ -0,0 +1,33 @@
+package prompts
+
+import "github.com/tmc/langchaingo/prompts"
+
+// HistorySummaryPrompt folds earlier turns of a conversation into its
+// running summary, so long threads fit the context of the answering model.
+var HistorySummaryPrompt = prompts.PromptTemplate{
+	Template: `<goal>
+You maintain the running summary of a conversation between a user and an assistant. Fold the new turns into the current summary.
+</goal>
+
+<instructions>
+1.  Keep what later questions may refer to: the topics asked about, the places, people, products, dates and numbers mentioned, and the conclusions of the answers.
+2.  Keep the order in which topics came up. Merge the new turns into the summary instead of appending a transcript.
+3.  Leave out greetings, formatting, source lists and citation markers.
+4.  Write in the third person ("The user asked…", "The assistant explained…") in at most {{.maxWords}} words.
+5.  If the current summary is "None.", summarize the new turns alone.
+</instructions>
+
+<current_summary>
+{{.summary}}
+</current_summary>
+
+<new_turns>
+{{.turns}}
+</new_turns>
+
+<output_format>
+{"summary": "updated summary"}
+</output_format>`,
+	TemplateFormat: prompts.TemplateFormatGoTemplate,
+	InputVariables: []string{"summary", "turns", "maxWords"},
+}
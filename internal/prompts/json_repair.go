@@ -0,0 +1,33 @@
+package prompts
+
+import "github.com/tmc/langchaingo/prompts"
+
+var JSONRepairPrompt = prompts.PromptTemplate{
+	Template: `<goal>
+    Your previous response to the task below could not be used because it was not valid JSON matching the required schema. Return a corrected response.
+    </goal>
+
+    <task>
+    {{.original_prompt}}
+    </task>
+
+    <previous_response>
+    {{.previous_output}}
+    </previous_response>
+
+    <errors>
+    {{.errors}}
+    </errors>
+
+    <json_schema>
+    {{.schema}}
+    </json_schema>
+
+    <instructions>
+    - Fix every listed error.
+    - Keep all correct content from the previous response.
+    - Return only the JSON object, with no commentary and no code fences.
+    </instructions>`,
+	TemplateFormat: prompts.TemplateFormatGoTemplate,
+	InputVariables: []string{"original_prompt", "previous_output", "errors", "schema"},
+}
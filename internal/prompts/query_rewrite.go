@@ -0,0 +1,38 @@
+package prompts
+
+import "github.com/tmc/langchaingo/prompts"
+
+// QueryRewritePrompt turns the latest message of a conversation into a
+// question that can be understood, searched and routed on its own.
+var QueryRewritePrompt = prompts.PromptTemplate{
+	Template: `<goal>
+You rewrite the latest user message of a conversation into a standalone query that means the same thing without the conversation.
+</goal>
+
+<instructions>
+1.  Read the conversation and the latest message.
+2.  If the latest message refers to earlier turns ("How about Kyoto?", "and his wife?", "compare it with the previous one"), replace the references with what they point to, keeping the intent of the earlier question.
+3.  If the latest message already stands on its own, return it unchanged.
+4.  Keep the user's wording, language, URLs, places and names. Do not answer the question and do not add details the conversation does not contain.
+</instructions>
+
+<example>
+Conversation:
+User: What's the weather like in Tokyo this weekend?
+Assistant: Sunny with highs around 24°C on Saturday and light rain on Sunday.
+Latest message: How about Kyoto?
+Output: {"query": "What's the weather like in Kyoto this weekend?"}
+</example>
+
+<conversation>
+{{.history}}
+</conversation>
+
+<latest_message>{{.query}}</latest_message>
+
+<output_format>
+{"query": "standalone query"}
+</output_format>`,
+	TemplateFormat: prompts.TemplateFormatGoTemplate,
+	InputVariables: []string{"history", "query"},
+}
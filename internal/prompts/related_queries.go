@@ -0,0 +1,35 @@
+package prompts
+
+import "github.com/tmc/langchaingo/prompts"
+
+var RelatedQueriesPrompt = prompts.PromptTemplate{
+	Template: `<goal>
+        You suggest the follow-up questions a curious user is most likely to ask next, after reading the answer to their question.
+        </goal>
+
+        <instructions>
+            - Write 3 to 6 follow-up questions.
+            - Each question must be self-contained: name its subject instead of saying "it", "they" or "this", so that it can be searched without the conversation.
+            - Go deeper into the answer or to closely related topics the sources cover; never repeat the original question or ask something the answer already settles.
+            - Keep each question under 15 words, phrased the way a person would type it.
+            - Write in the language of the original question.
+        </instructions>
+
+        <question>{{.query}}</question>
+
+        <sources>
+        {{.sources}}
+        </sources>
+
+        <answer>
+        {{.answer}}
+        </answer>
+
+        <output_format>
+        {
+        "questions": ["First follow-up question?", "Second follow-up question?", ...]
+        }
+        </output_format>`,
+	TemplateFormat: prompts.TemplateFormatGoTemplate,
+	InputVariables: []string{"query", "sources", "answer"},
+}
@@ -4,12 +4,13 @@ import "github.com/tmc/langchaingo/prompts"
 
 var Search_term_prompt = prompts.PromptTemplate{
 	Template: `<prompt>
-    <task>Extract the main specific search term from the given input string.</task>
+    <task>Extract the main specific search terms from the given input string, splitting a complex question into the web searches needed to answer it.</task>
     <instructions>
       <step>Read the input string associated with the given ID.</step>
       <step>Identify the most specific and central search term that best represents the user's query.</step>
+      <step>If the query asks several things at once, compares items or needs facts from different sources, write one self-contained search term per sub-question instead.</step>
       <step>Ignore general words, modifiers, or intent phrases (e.g., "how to", "best way to", "examples of").</step>
-      <step>Return only the core search term, as a single value, inside the JSON key "search_terms".</step>
+      <step>Return between 1 and {{.maxTerms}} search terms, most important first, inside the JSON key "search_term". A simple query needs exactly one.</step>
     </instructions>
     <input_string>{{.inputText}}</input_string>
     <output_format>
@@ -20,4 +21,6 @@ var Search_term_prompt = prompts.PromptTemplate{
       </json>
     </output_format>
   </prompt>`,
-	InputVariables: []string{"inputText"}}
+	TemplateFormat: prompts.TemplateFormatGoTemplate,
+	InputVariables: []string{"inputText", "maxTerms"},
+}
@@ -74,4 +74,7 @@ var SummaryPrompt = prompts.PromptTemplate{
         <input>
             Here is the text to process:
             {{.input_text}}
-        </input>`, InputVariables: []string{"input_text"}}
+        </input>`,
+	TemplateFormat: prompts.TemplateFormatGoTemplate,
+	InputVariables: []string{"input_text"},
+}
@@ -1,105 +1,34 @@
 package prompts
 
-import (
-	"strings"
-
-	"github.com/tmc/langchaingo/prompts"
-)
+import "github.com/tmc/langchaingo/prompts"
 
+// ToolDetectorPrompt is sent together with one function declaration per
+// registered tool; the model answers with a single function call. The
+// examples are rendered from the tools' own examples.
 var ToolDetectorPrompt = prompts.PromptTemplate{
-	Template: strings.ReplaceAll(`<goal>
+	Template: `<goal>
 You are an AI assistant that helps users by selecting the appropriate tool to handle their query and extracting necessary parameters for that tool.
-Your goal is to analyze the user's query and determine which of the available tools is most suitable.
-You must output a single JSON object string that specifies the tool and its parameters.
+Your goal is to analyze the user's query and determine which of the available functions is most suitable.
+You must respond by calling exactly one function with its arguments.
 </goal>
 
-<tools_available>
-1.  **youtube_summary**: Use this tool when the user asks to summarize a YouTube video.
-    - Required parameters:
-        - <<bt>>video_url<<bt>> (string): The full URL of the YouTube video to be summarized.
-2.  **weather_forecast**: Use this tool when the user asks about the weather.
-    - Optional parameters:
-        - <<bt>>location<<bt>> (string): The location for which the weather forecast is requested (e.g., "London", "Paris, FR"). If not specified, the assistant should try to infer it or ask for clarification if necessary (though for this task, just omit if not present in the query).
-3.  **nearby_businesses**: Use this tool when the user is looking for businesses or points of interest nearby or in a specified location.
-    - Optional parameters:
-        - <<bt>>location<<bt>> (string): The area to search for businesses (e.g., "San Francisco", "near me"). If not specified, the assistant should try to infer it.
-        - <<bt>>business_type<<bt>> (string): The category of business (e.g., "cafe", "restaurant", "electronics store", "coffee shops").
-        - <<bt>>keyword<<bt>> (string): A specific name or search term for a business (e.g., "Starbucks", "quiet study spot").
-4.  **general_search**: Use this tool as a default if the query does not clearly fit any of the other specialized tools, or if it's a general knowledge question.
-    - Parameters: No specific parameters are needed. The <<bt>>params<<bt>> object can be empty (e.g., <<bt>>{{}}<<bt>>).
-</tools_available>
-
 <instructions>
 1.  Read the user's query carefully.
-2.  Determine the most appropriate tool from the <tools_available> list.
-3.  If the query is ambiguous or doesn't fit a specialized tool, default to "general_search".
-4.  Extract the relevant parameters for the chosen tool based on the query.
-    - For <<bt>>youtube_summary<<bt>>, you MUST extract <<bt>>video_url<<bt>>.
-    - For <<bt>>weather_forecast<<bt>>, extract <<bt>>location<<bt>> if provided.
-    - for <<bt>>weather_forecast<<bt>>, if the user is requesting the current weather (i.e., looking for weather information for their current location) rather than a forecast for a specific location, then return an empty <<bt>>params<<bt>> object <<bt>>{}<<bt>>.
-    - For <<bt>>nearby_businesses<<bt>>, extract any of <<bt>>location<<bt>>, <<bt>>business_type<<bt>>, or <<bt>>keyword<<bt>> if provided.
-    - For <<bt>>general_search<<bt>>, <<bt>>params<<bt>> should be an empty object.
-5.  Format your output as a single JSON object string.
+2.  Determine the most appropriate function from the declared functions.
+3.  If the query is ambiguous or doesn't fit a specialized function, call "{{.default_tool}}".
+4.  Extract the arguments for the chosen function from the query.
+    - You MUST provide every required argument.
+    - Only include optional arguments that are present in the query.
+    - Never invent argument values that the query does not contain.
 </instructions>
 
-<output_format>
-Return a single JSON object string with the following structure:
-<<bt>><<bt>><<bt>>json
-{
-  "tool": "tool_name",
-  "params": {"param1": "value1", "param2": "value2", ...}
-}
-<<bt>><<bt>><<bt>>
-- <<bt>>tool_name<<bt>> must be one of: "youtube_summary", "weather_forecast", "nearby_businesses", "general_search".
-- <<bt>>params<<bt>> is an object containing the extracted parameters. If no parameters are applicable (e.g., for general_search or if optional parameters are not found), it can be an empty object <<bt>>{}<<bt>>.
-</output_format>
-
-<example_queries>
-User Query: "Can you summarize this YouTube video for me? https://www.youtube.com/watch?v=dQw4w9WgXcQ"
-Expected LLM Output (string containing JSON):
-<<bt>><<bt>><<bt>>json
-{"tool": "youtube_summary", "params": {"video_url": "https://www.youtube.com/watch?v=dQw4w9WgXcQ"}}
-<<bt>><<bt>><<bt>>
-
-User Query: "What's the weather like in Berlin?"
-Expected LLM Output:
-<<bt>><<bt>><<bt>>json
-{"tool": "weather_forecast", "params": {"location": "Berlin"}}
-<<bt>><<bt>><<bt>>
-User Query: "What's weather nearby/here/around me?"
-Expected LLM Output:
-<<bt>><<bt>><<bt>>json
-{"tool": "weather_forecast", "params": {}}
-<<bt>><<bt>><<bt>>
-User Query: "Weather today / weather forecast / current weather"
-Expected LLM Output:
-<<bt>><<bt>><<bt>>json
-{"tool": "weather_forecast", "params": {}}
-<<bt>><<bt>><<bt>>
-
-User Query: "Find me some coffee shops nearby."
-Expected LLM Output:
-<<bt>><<bt>><<bt>>json
-{"tool": "nearby_businesses", "params": {"business_type": "coffee shops"}}
-<<bt>><<bt>><<bt>>
-
-User Query: "Find me a Starbucks in downtown."
-Expected LLM Output:
-<<bt>><<bt>><<bt>>json
-{"tool": "nearby_businesses", "params": {"location": "downtown", "keyword": "Starbucks"}}
-<<bt>><<bt>><<bt>>
-
-User Query: "Tell me about Large Language Models."
-Expected LLM Output:
-<<bt>><<bt>><<bt>>json
-{"tool": "general_search", "params": {}}
-<<bt>><<bt>><<bt>>
+{{if .examples}}<example_queries>
+{{.examples}}
 </example_queries>
-
+{{end}}
 <user_query>
     {{.user_query}}
-</user_query>
-
-Your JSON Output:`, "<<bt>>", "`"),
-	InputVariables: []string{"user_query"},
+</user_query>`,
+	TemplateFormat: prompts.TemplateFormatGoTemplate,
+	InputVariables: []string{"default_tool", "examples", "user_query"},
 }
@@ -0,0 +1,25 @@
+package prompts
+
+// DefaultTuning holds the tuning instructions used by the answer prompts when
+// the caller does not supply its own.
+var DefaultTuning = map[string]any{
+	"verbosity_instruct":       "Balanced. Include the details that matter and skip filler.",
+	"response_length_instruct": "Medium. Match the length to the complexity of the query.",
+	"formal_level_instruct":    "Neutral and professional.",
+	"creativity_instruct":      "Low. Stay close to the provided data.",
+	"precision_instruct":       "High. Prefer exact figures, names and dates.",
+	"user_instruction":         "None.",
+}
+
+// WithTuning returns a copy of values with any missing tuning variables taken
+// from DefaultTuning.
+func WithTuning(values map[string]any) map[string]any {
+	merged := make(map[string]any, len(values)+len(DefaultTuning))
+	for k, v := range DefaultTuning {
+		merged[k] = v
+	}
+	for k, v := range values {
+		merged[k] = v
+	}
+	return merged
+}
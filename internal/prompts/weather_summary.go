@@ -2,7 +2,7 @@ package prompts
 
 import "github.com/tmc/langchaingo/prompts"
 
-var weather_summary_prompt = prompts.PromptTemplate{
+var WeatherSummaryPrompt = prompts.PromptTemplate{
 	Template: `<goal>Your task is to provide a concise, human-readable summary of the provided weather forecast data.</goal>
     <instructions>
     - Highlight the current weather conditions (temperature, general outlook like sunny/cloudy/rainy).
@@ -25,4 +25,6 @@ var weather_summary_prompt = prompts.PromptTemplate{
     Provide the summary directly. Example: "Currently it's 25°C and sunny. Expect similar weather tomorrow, with a high of 28°C. Rain is possible the day after."
     </summary_guidelines>
     Summary:`,
-	InputVariables: []string{"verbosity_instruct", "response_length_instruct", "formal_level_instruct", "creativity_instruct", "precision_instruct", "user_instruction", "weather_data"}}
+	TemplateFormat: prompts.TemplateFormatGoTemplate,
+	InputVariables: []string{"verbosity_instruct", "response_length_instruct", "formal_level_instruct", "creativity_instruct", "precision_instruct", "user_instruction", "weather_data"},
+}
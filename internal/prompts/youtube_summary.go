@@ -2,7 +2,7 @@ package prompts
 
 import "github.com/tmc/langchaingo/prompts"
 
-var youtube_summary_prompt = prompts.PromptTemplate{
+var YoutubeSummaryPrompt = prompts.PromptTemplate{
 	Template: `<goal>Your task is to provide a concise summary of the following YouTube video transcript.</goal>
     <instructions>
     - Focus on the main topics and key takeaways.
@@ -24,4 +24,7 @@ var youtube_summary_prompt = prompts.PromptTemplate{
     <summary_guidelines>
     Provide the summary directly, without any introductory phrases like "Here is the summary:".
     </summary_guidelines>
-    Summary:`, InputVariables: []string{"verbosity_instruct", "response_length_instruct", "formal_level_instruct", "creativity_instruct", "precision_instruct", "user_instruction", "transcript"}}
+    Summary:`,
+	TemplateFormat: prompts.TemplateFormatGoTemplate,
+	InputVariables: []string{"verbosity_instruct", "response_length_instruct", "formal_level_instruct", "creativity_instruct", "precision_instruct", "user_instruction", "transcript"},
+}
@@ -0,0 +1,199 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+const answerCacheCollection = "agios_answer_cache"
+
+// CachedAnswer is an answer stored for reuse by similar queries.
+type CachedAnswer struct {
+	Query        string          `json:"query"`
+	Tool         string          `json:"tool"`
+	MessageID    uuid.UUID       `json:"message_id"`
+	ResponseText string          `json:"response_text"`
+	EventType    string          `json:"event_type"`
+	WebResults   json.RawMessage `json:"web_results,omitempty"`
+	Widget       map[string]any  `json:"widget,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	// Similarity is the cosine similarity of the lookup query to Query.
+	Similarity float32 `json:"-"`
+}
+
+// AnswerCacheRepository stores answers in Qdrant by the embedding of their
+// query.
+type AnswerCacheRepository interface {
+	// Nearest returns the answer of tool whose query is most similar to
+	// vector, if it scores at least minScore and was stored after since.
+	// It returns nil when there is none.
+	Nearest(ctx context.Context, vector []float32, tool string, minScore float32, since time.Time) (*CachedAnswer, error)
+	Store(ctx context.Context, vector []float32, answer *CachedAnswer) error
+	// Prepare makes the collection hold vectors of size dims. A collection
+	// of another size, written with an earlier embedding model, is dropped
+	// and created again, as its vectors cannot be compared with new ones.
+	Prepare(ctx context.Context, dims int) error
+}
+
+type answerCacheRepo struct {
+	client *qdrant.Client
+
+	// ready is set once the collection is known to exist.
+	mu    sync.Mutex
+	ready bool
+}
+
+func NewAnswerCacheRepository(client *qdrant.Client) AnswerCacheRepository {
+	return &answerCacheRepo{client: client}
+}
+
+func (r *answerCacheRepo) Nearest(ctx context.Context, vector []float32, tool string, minScore float32, since time.Time) (*CachedAnswer, error) {
+	exists, err := r.ensureCollection(ctx, 0)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	points, err := r.client.Query(ctx, &qdrant.QueryPoints{
+		CollectionName: answerCacheCollection,
+		Query:          qdrant.NewQueryDense(vector),
+		Filter: &qdrant.Filter{
+			Must: []*qdrant.Condition{
+				qdrant.NewMatchKeyword("tool", tool),
+				qdrant.NewRange("created_at", &qdrant.Range{Gte: qdrant.PtrOf(float64(since.Unix()))}),
+			},
+		},
+		ScoreThreshold: qdrant.PtrOf(minScore),
+		Limit:          qdrant.PtrOf(uint64(1)),
+		WithPayload:    qdrant.NewWithPayload(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(points) == 0 {
+		return nil, nil
+	}
+
+	var answer CachedAnswer
+	if err := json.Unmarshal([]byte(points[0].Payload["answer"].GetStringValue()), &answer); err != nil {
+		return nil, fmt.Errorf("failed to decode cached answer: %w", err)
+	}
+	answer.Similarity = points[0].Score
+	return &answer, nil
+}
+
+func (r *answerCacheRepo) Store(ctx context.Context, vector []float32, answer *CachedAnswer) error {
+	if _, err := r.ensureCollection(ctx, len(vector)); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(answer)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.Upsert(ctx, &qdrant.UpsertPoints{
+		CollectionName: answerCacheCollection,
+		Points: []*qdrant.PointStruct{{
+			Id:      qdrant.NewIDUUID(uuid.NewString()),
+			Vectors: qdrant.NewVectorsDense(vector),
+			Payload: qdrant.NewValueMap(map[string]any{
+				"tool":       answer.Tool,
+				"created_at": float64(answer.CreatedAt.Unix()),
+				"answer":     string(data),
+			}),
+		}},
+	})
+	return err
+}
+
+func (r *answerCacheRepo) Prepare(ctx context.Context, dims int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	exists, err := r.client.CollectionExists(ctx, answerCacheCollection)
+	if err != nil {
+		return err
+	}
+	if exists {
+		info, err := r.client.GetCollectionInfo(ctx, answerCacheCollection)
+		if err != nil {
+			return err
+		}
+		size := info.GetConfig().GetParams().GetVectorsConfig().GetParams().GetSize()
+		if size == uint64(dims) {
+			r.ready = true
+			return nil
+		}
+		log.Printf("Answer cache holds vectors of size %d, the embedding model gives %d; recreating it", size, dims)
+		if err := r.client.DeleteCollection(ctx, answerCacheCollection); err != nil {
+			return err
+		}
+	}
+
+	if err := r.createCollection(ctx, dims); err != nil {
+		return err
+	}
+	r.ready = true
+	return nil
+}
+
+// ensureCollection reports whether the collection exists, creating it for
+// vectors of size dims when dims is not 0.
+func (r *answerCacheRepo) ensureCollection(ctx context.Context, dims int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.ready {
+		return true, nil
+	}
+
+	exists, err := r.client.CollectionExists(ctx, answerCacheCollection)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		if dims == 0 {
+			return false, nil
+		}
+		if err := r.createCollection(ctx, dims); err != nil {
+			return false, err
+		}
+	}
+
+	r.ready = true
+	return true, nil
+}
+
+// createCollection creates the collection for vectors of size dims, with
+// the payload indexes Nearest filters on.
+func (r *answerCacheRepo) createCollection(ctx context.Context, dims int) error {
+	if err := r.client.CreateCollection(ctx, &qdrant.CreateCollection{
+		CollectionName: answerCacheCollection,
+		VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{
+			Size:     uint64(dims),
+			Distance: qdrant.Distance_Cosine,
+		}),
+	}); err != nil {
+		return err
+	}
+	for field, kind := range map[string]qdrant.FieldType{
+		"tool":       qdrant.FieldType_FieldTypeKeyword,
+		"created_at": qdrant.FieldType_FieldTypeFloat,
+	} {
+		if _, err := r.client.CreateFieldIndex(ctx, &qdrant.CreateFieldIndexCollection{
+			CollectionName: answerCacheCollection,
+			FieldName:      field,
+			FieldType:      qdrant.PtrOf(kind),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
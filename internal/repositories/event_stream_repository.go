@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// eventStreamTTL bounds how long a stream is kept while it is written.
+	eventStreamTTL = 24 * time.Hour
+	// finishedEventStreamTTL is how long clients can still replay a finished stream.
+	finishedEventStreamTTL = time.Hour
+)
+
+// StreamedEvent is one SSE event recorded for a message.
+type StreamedEvent struct {
+	Seq   int64
+	Event string
+	Data  string
+}
+
+// EventStreamRepository stores the SSE events of a message in a Redis Stream
+// so that they can be replayed to reconnecting clients.
+type EventStreamRepository interface {
+	Append(ctx context.Context, messageID uuid.UUID, event StreamedEvent) error
+	// Read returns the events after afterSeq, waiting up to block for new
+	// ones. It returns no events and no error when block elapses.
+	Read(ctx context.Context, messageID uuid.UUID, afterSeq int64, block time.Duration) ([]StreamedEvent, error)
+	Exists(ctx context.Context, messageID uuid.UUID) (bool, error)
+	Finish(ctx context.Context, messageID uuid.UUID) error
+	// Delete drops the stream, e.g. before the message is generated again.
+	Delete(ctx context.Context, messageID uuid.UUID) error
+}
+
+type eventStreamRepo struct {
+	rdb *redis.Client
+}
+
+func NewEventStreamRepository(rdb *redis.Client) EventStreamRepository {
+	return &eventStreamRepo{rdb: rdb}
+}
+
+func eventStreamKey(messageID uuid.UUID) string {
+	return fmt.Sprintf("agios:messages:%s:events", messageID)
+}
+
+// Append stores event under the explicit stream ID 0-<seq>, so stream IDs and
+// SSE event IDs line up one to one.
+func (r *eventStreamRepo) Append(ctx context.Context, messageID uuid.UUID, event StreamedEvent) error {
+	key := eventStreamKey(messageID)
+
+	pipe := r.rdb.TxPipeline()
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: key,
+		ID:     fmt.Sprintf("0-%d", event.Seq),
+		Values: map[string]any{"event": event.Event, "data": event.Data},
+	})
+	if event.Seq == 1 {
+		pipe.Expire(ctx, key, eventStreamTTL)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *eventStreamRepo) Read(ctx context.Context, messageID uuid.UUID, afterSeq int64, block time.Duration) ([]StreamedEvent, error) {
+	streams, err := r.rdb.XRead(ctx, &redis.XReadArgs{
+		Streams: []string{eventStreamKey(messageID), fmt.Sprintf("0-%d", afterSeq)},
+		Block:   block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var events []StreamedEvent
+	for _, stream := range streams {
+		for _, msg := range stream.Messages {
+			_, seqStr, _ := strings.Cut(msg.ID, "-")
+			seq, err := strconv.ParseInt(seqStr, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid event stream ID %q: %w", msg.ID, err)
+			}
+			event, _ := msg.Values["event"].(string)
+			data, _ := msg.Values["data"].(string)
+			events = append(events, StreamedEvent{Seq: seq, Event: event, Data: data})
+		}
+	}
+	return events, nil
+}
+
+func (r *eventStreamRepo) Exists(ctx context.Context, messageID uuid.UUID) (bool, error) {
+	n, err := r.rdb.Exists(ctx, eventStreamKey(messageID)).Result()
+	return n > 0, err
+}
+
+// Finish shortens the retention of a stream whose generation has ended.
+func (r *eventStreamRepo) Finish(ctx context.Context, messageID uuid.UUID) error {
+	return r.rdb.Expire(ctx, eventStreamKey(messageID), finishedEventStreamTTL).Err()
+}
+
+func (r *eventStreamRepo) Delete(ctx context.Context, messageID uuid.UUID) error {
+	return r.rdb.Del(ctx, eventStreamKey(messageID)).Err()
+}
@@ -0,0 +1,29 @@
+package repositories
+
+import (
+	"context"
+
+	"agios/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// MessageEventRepository persists the SSE events of messages.
+type MessageEventRepository interface {
+	CreateEvents(ctx context.Context, events []models.MessageEvent) error
+}
+
+type messageEventRepo struct {
+	db *gorm.DB
+}
+
+func NewMessageEventRepository(db *gorm.DB) MessageEventRepository {
+	return &messageEventRepo{db: db}
+}
+
+func (r *messageEventRepo) CreateEvents(ctx context.Context, events []models.MessageEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).CreateInBatches(events, 100).Error
+}
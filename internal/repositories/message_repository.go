@@ -4,14 +4,23 @@ import (
 	"context"
 
 	"agios/internal/models"
+	"agios/internal/utils/constant"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type MessageRepository interface {
+	GetMessage(ctx context.Context, messageID uuid.UUID) (*models.Message, error)
 	DeleteMessage(ctx context.Context, messageID uuid.UUID) error
 	CreateMessage(ctx context.Context, message *models.Message) error
+	UpdateMessage(ctx context.Context, message *models.Message) error
+	ClaimMessage(ctx context.Context, messageID uuid.UUID) (bool, error)
+	CreateNextMessage(ctx context.Context, message *models.Message) error
+	GetThreadHistory(ctx context.Context, threadID uuid.UUID, beforeIndex int) ([]models.Message, error)
+	CountVersions(ctx context.Context, messageID uuid.UUID) (int64, error)
+	CreateVersion(ctx context.Context, message *models.Message) (*models.MessageVersion, error)
 }
 
 type messageRepo struct {
@@ -22,6 +31,15 @@ func NewMessageRepository(db *gorm.DB) MessageRepository {
 	return &messageRepo{db: db}
 }
 
+func (r *messageRepo) GetMessage(ctx context.Context, messageID uuid.UUID) (*models.Message, error) {
+	var message models.Message
+	result := r.db.WithContext(ctx).Preload("Files").Where("id = ?", messageID).First(&message)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &message, nil
+}
+
 func (r *messageRepo) DeleteMessage(ctx context.Context, messageID uuid.UUID) error {
 	result := r.db.WithContext(ctx).Delete(&models.Message{}, messageID)
 	if result.Error != nil {
@@ -36,3 +54,95 @@ func (r *messageRepo) DeleteMessage(ctx context.Context, messageID uuid.UUID) er
 func (r *messageRepo) CreateMessage(ctx context.Context, message *models.Message) error {
 	return r.db.WithContext(ctx).Create(message).Error
 }
+
+func (r *messageRepo) UpdateMessage(ctx context.Context, message *models.Message) error {
+	return r.db.WithContext(ctx).Omit(clause.Associations).Save(message).Error
+}
+
+// CreateNextMessage stores message with the next free MessageIndex of its
+// thread. The thread row is locked so concurrent follow-ups cannot claim the
+// same index.
+func (r *messageRepo) CreateNextMessage(ctx context.Context, message *models.Message) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var thread models.Thread
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", message.ThreadID).First(&thread).Error; err != nil {
+			return err
+		}
+
+		var next int
+		if err := tx.Model(&models.Message{}).Where("thread_id = ?", message.ThreadID).Select("COALESCE(MAX(message_index) + 1, 0)").Scan(&next).Error; err != nil {
+			return err
+		}
+
+		message.MessageIndex = next
+		return tx.Create(message).Error
+	})
+}
+
+// ClaimMessage marks a message that is not in progress IN_PROGRESS and
+// reports whether it did. Of concurrent claims only one succeeds.
+func (r *messageRepo) ClaimMessage(ctx context.Context, messageID uuid.UUID) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Message{}).
+		Where("id = ? AND (stream_status IS NULL OR stream_status <> ?)", messageID, constant.StreamStatusInProgress).
+		Update("stream_status", constant.StreamStatusInProgress)
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetThreadHistory returns the messages of a thread that precede beforeIndex,
+// oldest first.
+func (r *messageRepo) GetThreadHistory(ctx context.Context, threadID uuid.UUID, beforeIndex int) ([]models.Message, error) {
+	var messages []models.Message
+	err := r.db.WithContext(ctx).
+		Where("thread_id = ? AND message_index < ?", threadID, beforeIndex).
+		Order("message_index ASC").
+		Find(&messages).Error
+	return messages, err
+}
+
+func (r *messageRepo) CountVersions(ctx context.Context, messageID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.MessageVersion{}).Where("message_id = ?", messageID).Count(&count).Error
+	return count, err
+}
+
+// CreateVersion stores the current answer of message as its next version and
+// selects it. The message row is locked so concurrent saves cannot claim the
+// same index.
+func (r *messageRepo) CreateVersion(ctx context.Context, message *models.Message) (*models.MessageVersion, error) {
+	var version *models.MessageVersion
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var locked models.Message
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", message.ID).First(&locked).Error; err != nil {
+			return err
+		}
+
+		var next int
+		if err := tx.Model(&models.MessageVersion{}).Where("message_id = ?", message.ID).Select("COALESCE(MAX(version_index) + 1, 0)").Scan(&next).Error; err != nil {
+			return err
+		}
+
+		version = &models.MessageVersion{
+			MessageID:    message.ID,
+			VersionIndex: next,
+			ResponseText: message.ResponseText,
+			EventType:    message.EventType,
+			Model:        message.Model,
+			InputToken:   message.InputToken,
+			OutputToken:  message.OutputToken,
+			ResponseTime: message.ResponseTime,
+			Cost:         message.Cost,
+			StreamStatus: message.StreamStatus,
+			MetaData:     message.MetaData,
+		}
+		if err := tx.Create(version).Error; err != nil {
+			return err
+		}
+
+		message.SelectedVersion = next
+		return tx.Model(&models.Message{}).Where("id = ?", message.ID).Update("selected_version", next).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return version, nil
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ThreadRepository interface {
@@ -14,6 +15,12 @@ type ThreadRepository interface {
 	GetThreadWithMessages(ctx context.Context, threadID uuid.UUID) (*models.Thread, error)
 	GetThreadBySlug(ctx context.Context, slug string) (*models.Thread, error)
 	CreateThread(ctx context.Context, thread *models.Thread) error
+	GetThread(ctx context.Context, threadID uuid.UUID) (*models.Thread, error)
+	ForkThread(ctx context.Context, source *models.Thread, branch *models.Thread, edited *models.Message) error
+	ListBranches(ctx context.Context, rootID uuid.UUID) ([]models.Thread, error)
+	SetActiveBranch(ctx context.Context, rootID, branchID uuid.UUID) error
+	UpdateHistorySummary(ctx context.Context, threadID uuid.UUID, summary string, throughIndex int) error
+	ResetHistorySummary(ctx context.Context, threadID uuid.UUID, fromIndex int) error
 }
 
 type threadRepo struct {
@@ -38,7 +45,11 @@ func (r *threadRepo) DeleteThread(ctx context.Context, threadID uuid.UUID) error
 func (r *threadRepo) GetThreadWithMessages(ctx context.Context, threadID uuid.UUID) (*models.Thread, error) {
 	var thread models.Thread
 	result := r.db.WithContext(ctx).Preload("Messages", func(db *gorm.DB) *gorm.DB {
-		return db.Order("messages.created_at ASC")
+		return db.Order("messages.message_index ASC")
+	}).Preload("Messages.AnswerVersions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("message_versions.version_index ASC")
+	}).Preload("Messages.Events", func(db *gorm.DB) *gorm.DB {
+		return db.Order("message_events.version_index ASC, message_events.sequence ASC")
 	}).Where("id = ?", threadID).First(&thread)
 	if result.Error != nil {
 		return nil, result.Error
@@ -61,3 +72,143 @@ func (r *threadRepo) GetThreadBySlug(ctx context.Context, slug string) (*models.
 func (r *threadRepo) CreateThread(ctx context.Context, thread *models.Thread) error {
 	return r.db.WithContext(ctx).Create(thread).Error
 }
+
+func (r *threadRepo) GetThread(ctx context.Context, threadID uuid.UUID) (*models.Thread, error) {
+	var thread models.Thread
+	result := r.db.WithContext(ctx).Where("id = ?", threadID).First(&thread)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &thread, nil
+}
+
+// ForkThread creates branch from source. It copies the messages before
+// edited.MessageIndex with their files and the events of their selected
+// answers, together with the history summary of those messages. It then
+// stores edited after the copies and makes branch the active branch of the
+// family. branch.Messages is set to the copied messages.
+func (r *threadRepo) ForkThread(ctx context.Context, source *models.Thread, branch *models.Thread, edited *models.Message) error {
+	rootID := source.ID
+	if source.RootThreadID != nil {
+		rootID = *source.RootThreadID
+	}
+	forkIndex := edited.MessageIndex
+
+	branch.ParentThreadID = &source.ID
+	branch.RootThreadID = &rootID
+	branch.ForkMessageIndex = &forkIndex
+	// The copied messages keep the summary of the source when it covers
+	// none of the messages left behind.
+	if source.SummaryThroughIndex != nil && *source.SummaryThroughIndex < forkIndex {
+		branch.HistorySummary = source.HistorySummary
+		branch.SummaryThroughIndex = source.SummaryThroughIndex
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(branch).Error; err != nil {
+			return err
+		}
+
+		var originals []models.Message
+		if err := tx.Preload("Files").
+			Where("thread_id = ? AND message_index < ?", source.ID, forkIndex).
+			Order("message_index ASC").
+			Find(&originals).Error; err != nil {
+			return err
+		}
+
+		copies := make([]models.Message, 0, len(originals))
+		for _, m := range originals {
+			copies = append(copies, models.Message{
+				ThreadID:     branch.ID,
+				QueryText:    m.QueryText,
+				ResponseText: m.ResponseText,
+				EventType:    m.EventType,
+				Model:        m.Model,
+				InputToken:   m.InputToken,
+				OutputToken:  m.OutputToken,
+				ResponseTime: m.ResponseTime,
+				Cost:         m.Cost,
+				StreamStatus: m.StreamStatus,
+				MessageIndex: m.MessageIndex,
+				MetaData:     m.MetaData,
+				Files:        m.Files,
+			})
+		}
+		if len(copies) > 0 {
+			if err := tx.Omit("Files.*").Create(&copies).Error; err != nil {
+				return err
+			}
+		}
+
+		for i, m := range originals {
+			if err := tx.Exec(`INSERT INTO message_events (message_id, version_index, sequence, event_type, payload, created_at)
+				SELECT ?, 0, sequence, event_type, payload, created_at FROM message_events
+				WHERE message_id = ? AND version_index = ?`, copies[i].ID, m.ID, m.SelectedVersion).Error; err != nil {
+				return err
+			}
+		}
+
+		edited.ThreadID = branch.ID
+		if err := tx.Omit("Files.*").Create(edited).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Thread{}).Where("id = ?", rootID).Update("active_branch_id", branch.ID).Error; err != nil {
+			return err
+		}
+
+		branch.Messages = copies
+		return nil
+	})
+}
+
+// ListBranches returns the root thread and every branch forked from it,
+// oldest first.
+func (r *threadRepo) ListBranches(ctx context.Context, rootID uuid.UUID) ([]models.Thread, error) {
+	var threads []models.Thread
+	err := r.db.WithContext(ctx).
+		Where("id = ? OR root_thread_id = ?", rootID, rootID).
+		Order("created_at ASC").
+		Find(&threads).Error
+	return threads, err
+}
+
+// SetActiveBranch records branchID as the branch the user is on. The root
+// row is locked so that the membership check and the update agree.
+func (r *threadRepo) SetActiveBranch(ctx context.Context, rootID, branchID uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var root models.Thread
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", rootID).First(&root).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.Thread{}).Where("id = ? AND (id = ? OR root_thread_id = ?)", branchID, rootID, rootID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Model(&models.Thread{}).Where("id = ?", rootID).Update("active_branch_id", branchID).Error
+	})
+}
+
+// UpdateHistorySummary stores summary as the running summary of the messages
+// of a thread up to throughIndex. A summary that covers less than the stored
+// one is ignored, so concurrent answers cannot roll it back.
+func (r *threadRepo) UpdateHistorySummary(ctx context.Context, threadID uuid.UUID, summary string, throughIndex int) error {
+	return r.db.WithContext(ctx).Model(&models.Thread{}).
+		Where("id = ? AND (summary_through_index IS NULL OR summary_through_index < ?)", threadID, throughIndex).
+		Updates(map[string]any{"history_summary": summary, "summary_through_index": throughIndex}).Error
+}
+
+// ResetHistorySummary drops the summary of a thread if it covers the message
+// at fromIndex, whose answer has changed. The next answer summarizes the
+// thread again.
+func (r *threadRepo) ResetHistorySummary(ctx context.Context, threadID uuid.UUID, fromIndex int) error {
+	return r.db.WithContext(ctx).Model(&models.Thread{}).
+		Where("id = ? AND summary_through_index >= ?", threadID, fromIndex).
+		Updates(map[string]any{"history_summary": nil, "summary_through_index": nil}).Error
+}
@@ -0,0 +1,140 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"agios/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Dimensions a usage report can be grouped by.
+const (
+	UsageByDay   = "day"
+	UsageByModel = "model"
+	UsageByTool  = "tool"
+	UsageByStage = "stage"
+	UsageByUser  = "user"
+)
+
+// usageDimensions maps each dimension to the SQL expression it groups by.
+var usageDimensions = map[string]string{
+	UsageByDay:   "to_char(date_trunc('day', llm_calls.created_at), 'YYYY-MM-DD')",
+	UsageByModel: "llm_calls.model",
+	UsageByTool:  "llm_calls.tool",
+	UsageByStage: "llm_calls.stage",
+	UsageByUser:  "COALESCE(threads.user_id::text, '')",
+}
+
+// ValidUsageDimension reports whether a report can be grouped by dim.
+func ValidUsageDimension(dim string) bool {
+	_, ok := usageDimensions[dim]
+	return ok
+}
+
+// UsageQuery selects the calls made in [From, To) and the dimensions to
+// group them by. A zero bound is open.
+type UsageQuery struct {
+	From    time.Time
+	To      time.Time
+	GroupBy []string
+}
+
+// UsageRow is one group of a usage report. Dimensions that are not grouped
+// by are empty.
+type UsageRow struct {
+	Day          string
+	Model        string
+	Tool         string
+	Stage        string
+	UserID       string
+	InputTokens  int64
+	OutputTokens int64
+	Cost         float64
+	Requests     int64 // distinct messages
+	Calls        int64
+}
+
+// UsageRepository stores the LLM calls made while answering messages and
+// reports on them.
+type UsageRepository interface {
+	RecordCalls(ctx context.Context, calls []models.LLMCall) error
+	Summarize(ctx context.Context, query UsageQuery) ([]UsageRow, error)
+	// SpendSince returns the USD cost of the calls made since t.
+	SpendSince(ctx context.Context, t time.Time) (float64, error)
+}
+
+type usageRepo struct {
+	db *gorm.DB
+}
+
+func NewUsageRepository(db *gorm.DB) UsageRepository {
+	return &usageRepo{db: db}
+}
+
+func (r *usageRepo) RecordCalls(ctx context.Context, calls []models.LLMCall) error {
+	if len(calls) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Create(&calls).Error
+}
+
+// Summarize groups the calls matching query. Unknown dimensions are ignored;
+// callers validate them with ValidUsageDimension.
+func (r *usageRepo) Summarize(ctx context.Context, query UsageQuery) ([]UsageRow, error) {
+	selects := []string{}
+	groups := []string{}
+	for _, dim := range []string{UsageByDay, UsageByModel, UsageByTool, UsageByStage, UsageByUser} {
+		expr := "''"
+		for _, g := range query.GroupBy {
+			if g == dim {
+				expr = usageDimensions[dim]
+				groups = append(groups, expr)
+				break
+			}
+		}
+		alias := dim
+		if dim == UsageByUser {
+			alias = "user_id" // user is reserved in Postgres
+		}
+		selects = append(selects, expr+" AS "+alias)
+	}
+	selects = append(selects,
+		"COALESCE(SUM(llm_calls.input_token), 0) AS input_tokens",
+		"COALESCE(SUM(llm_calls.output_token), 0) AS output_tokens",
+		"COALESCE(SUM(llm_calls.cost), 0) AS cost",
+		"COUNT(DISTINCT llm_calls.message_id) AS requests",
+		"COUNT(*) AS calls",
+	)
+
+	tx := r.db.WithContext(ctx).
+		Table("llm_calls").
+		Select(strings.Join(selects, ", ")).
+		Joins("JOIN messages ON messages.id = llm_calls.message_id").
+		Joins("JOIN threads ON threads.id = messages.thread_id")
+	if !query.From.IsZero() {
+		tx = tx.Where("llm_calls.created_at >= ?", query.From)
+	}
+	if !query.To.IsZero() {
+		tx = tx.Where("llm_calls.created_at < ?", query.To)
+	}
+	if len(groups) > 0 {
+		tx = tx.Group(strings.Join(groups, ", ")).Order(strings.Join(groups, ", "))
+	}
+
+	var rows []UsageRow
+	err := tx.Scan(&rows).Error
+	return rows, err
+}
+
+func (r *usageRepo) SpendSince(ctx context.Context, t time.Time) (float64, error) {
+	var spent float64
+	err := r.db.WithContext(ctx).
+		Model(&models.LLMCall{}).
+		Where("created_at >= ?", t).
+		Select("COALESCE(SUM(cost), 0)").
+		Scan(&spent).Error
+	return spent, err
+}
@@ -0,0 +1,608 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"agios/internal/config"
+	"agios/internal/models"
+	"agios/internal/repositories"
+	"agios/internal/tools"
+	"agios/internal/utils/citation"
+	"agios/internal/utils/constant"
+	extract "agios/internal/utils/extract"
+	"agios/internal/utils/helpers"
+	"agios/internal/utils/llm"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+const eventVersion = "1.0"
+
+// EventSender delivers a single server-sent event. sse.SSEWriter satisfies it.
+type EventSender interface {
+	SendEvent(event string, data string) error
+}
+
+// AnswerRequest carries everything the pipeline needs to answer one message.
+type AnswerRequest struct {
+	Message *models.Message
+	// History holds the earlier messages of the thread, oldest first.
+	History  []models.Message
+	ClientIP string
+	// Model, when set, answers every stage instead of the models routed
+	// to them; it is the caller's choice or the budget's fallback.
+	Model string
+	// CacheMode says how the run uses the LLM response cache.
+	CacheMode llm.CacheMode
+	// SaveVersion stores the answer as a new version of the message, as
+	// regeneration does. VersionIndex is the index that version will get.
+	SaveVersion  bool
+	VersionIndex int
+}
+
+// AnswerService runs the answer pipeline for a stored message and streams
+// its progress as SSE events.
+type AnswerService interface {
+	Answer(ctx context.Context, req AnswerRequest, events EventSender) error
+}
+
+// NewAnswerService constructs an AnswerService that dispatches to the tools
+// in registry.
+func NewAnswerService(messageRepo repositories.MessageRepository, threadRepo repositories.ThreadRepository, usageRepo repositories.UsageRepository, semanticCache SemanticCacheService, registry *tools.Registry, cfg *config.Config) AnswerService {
+	return &answerServiceImpl{messageRepo: messageRepo, threadRepo: threadRepo, usageRepo: usageRepo, semanticCache: semanticCache, registry: registry, cfg: cfg, citationMode: cfg.CitationMode, rewriteTurns: cfg.QueryRewriteTurns}
+}
+
+type answerServiceImpl struct {
+	messageRepo   repositories.MessageRepository
+	threadRepo    repositories.ThreadRepository
+	usageRepo     repositories.UsageRepository
+	semanticCache SemanticCacheService
+	registry      *tools.Registry
+	// cfg supplies the history budgets and the model routing.
+	cfg          *config.Config
+	citationMode string
+	// rewriteTurns earlier messages are used to rewrite follow-ups.
+	rewriteTurns int
+}
+
+// answerRun tracks the events of a single pipeline execution. It is the
+// tools.Emitter handed to the chosen tool.
+type answerRun struct {
+	events EventSender
+	req    AnswerRequest
+	// query is the query the tools answer: the message's query, or its
+	// standalone rewrite.
+	query string
+	// rewrittenFrom is the query as asked, set once the rewriter has run.
+	rewrittenFrom string
+	// webResults is the payload of the last WEB_RESULTS event.
+	webResults json.RawMessage
+	// reused is the cached answer served instead of running the tool.
+	reused *repositories.CachedAnswer
+	// citations checks the markers of an answer written from webResults.
+	citations    *citation.Filter
+	citationMode string
+}
+
+func (r *answerRun) Send(event string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if event == constant.EventWebResults {
+		r.webResults = data
+	}
+	return r.events.SendEvent(event, string(data))
+}
+
+func (r *answerRun) Plan(cot string) error {
+	return r.PlanDetails(cot, nil)
+}
+
+func (r *answerRun) PlanDetails(cot string, details map[string]any) error {
+	payload := map[string]any{
+		"version":   eventVersion,
+		"cot":       cot,
+		"streaming": true,
+	}
+	for k, v := range details {
+		payload[k] = v
+	}
+	return r.Send(constant.EventPlan, payload)
+}
+
+func (r *answerRun) Widget(widgetType string, widgetData any) (map[string]any, error) {
+	payload := map[string]any{
+		"version":     eventVersion,
+		"widget_type": widgetType,
+		"streaming":   true,
+		"widget_data": widgetData,
+	}
+	return payload, r.Send(constant.EventWidget, payload)
+}
+
+// StreamMarkdown forwards every chunk of stream as a MARKDOWN_ANSWER event
+// and returns the full text. An answer written from web results has its
+// citation markers checked on the way.
+func (r *answerRun) StreamMarkdown(stream *llm.Stream) (string, error) {
+	filter := r.citationFilter()
+
+	var text strings.Builder
+	send := func(chunk string) error {
+		if chunk == "" {
+			return nil
+		}
+		text.WriteString(chunk)
+		return r.Send(constant.EventMarkdownAnswer, map[string]any{
+			"chunk":     chunk,
+			"streaming": true,
+		})
+	}
+
+	_, _, err := llm.ConsumeStream(stream, func(chunk string) error {
+		if filter != nil {
+			chunk = filter.Write(chunk)
+		}
+		return send(chunk)
+	})
+	if filter != nil {
+		if flushErr := send(filter.Flush()); err == nil {
+			err = flushErr
+		}
+	}
+	return text.String(), err
+}
+
+// citationFilter returns the citation filter of the run, or nil if no web
+// results were sent.
+func (r *answerRun) citationFilter() *citation.Filter {
+	if r.webResults == nil {
+		return nil
+	}
+	if r.citations == nil {
+		r.citations = citation.NewFilter(citation.SourcesFromWebResults(r.webResults), r.citationMode)
+	}
+	return r.citations
+}
+
+// filePaths returns the on-disk paths of the files attached to the message.
+func (r *answerRun) filePaths() []string {
+	paths := make([]string, 0, len(r.req.Message.Files))
+	for _, f := range r.req.Message.Files {
+		paths = append(paths, filepath.Join(uploadDir, f.FileName))
+	}
+	return paths
+}
+
+// fileContext describes the attached files for prompts that take a
+// file_context variable. The file contents themselves travel as parts.
+func (r *answerRun) fileContext() string {
+	if len(r.req.Message.Files) == 0 {
+		return "None."
+	}
+	names := make([]string, 0, len(r.req.Message.Files))
+	for _, f := range r.req.Message.Files {
+		names = append(names, f.OriginalFileName)
+	}
+	return "Attached files (provided alongside this prompt): " + strings.Join(names, ", ")
+}
+
+// renderTurns renders messages as a User/Assistant transcript. Answers
+// longer than maxAnswer runes are cut; 0 keeps them whole.
+func renderTurns(messages []models.Message, maxAnswer int) string {
+	var b strings.Builder
+	for _, m := range messages {
+		fmt.Fprintf(&b, "User: %s\n", *m.QueryText)
+		if m.ResponseText != nil {
+			answer := *m.ResponseText
+			if runes := []rune(answer); maxAnswer > 0 && len(runes) > maxAnswer {
+				answer = string(runes[:maxAnswer]) + "…"
+			}
+			fmt.Fprintf(&b, "Assistant: %s\n", answer)
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// Answer runs tool detection, executes the chosen tool, streams the result
+// and records the outcome on the message.
+func (s *answerServiceImpl) Answer(ctx context.Context, req AnswerRequest, events EventSender) error {
+	started := time.Now()
+	req.History = conversationTurns(req.History)
+	run := &answerRun{events: events, req: req, citationMode: s.citationMode}
+	if req.Message.QueryText != nil {
+		run.query = *req.Message.QueryText
+	}
+	usage := &llm.Usage{}
+	ctx = llm.WithUsage(llm.WithModel(ctx, req.Model), usage)
+	ctx = llm.WithCacheMode(ctx, req.CacheMode)
+
+	run.Send(constant.EventStart, map[string]any{
+		"streaming":  true,
+		"message_id": req.Message.ID,
+		"thread_id":  req.Message.ThreadID,
+	})
+	run.Plan(constant.COTStarted)
+
+	result, runErr := s.run(ctx, run)
+
+	var related []string
+	if runErr == nil && result != nil && result.ResponseText != "" {
+		related = run.relatedQueries(ctx, result)
+	}
+
+	msg := req.Message
+	msg.ResponseTime = time.Since(started).Seconds()
+
+	meta := map[string]any{}
+	if len(msg.MetaData) > 0 {
+		_ = json.Unmarshal(msg.MetaData, &meta)
+	}
+
+	// Every LLM call of the run counts, including those of failed attempts.
+	inputTokens, outputTokens, cost, llmTime := usage.Totals()
+	msg.InputToken = inputTokens
+	msg.OutputToken = outputTokens
+	msg.Cost = cost
+	if model := answerModel(usage.Calls()); model != "" {
+		msg.Model = model
+	}
+	meta["usage"] = map[string]any{
+		"calls":       usage.Calls(),
+		"llm_seconds": llmTime.Seconds(),
+	}
+
+	tool := ""
+	if result != nil {
+		tool = result.Tool
+		msg.ResponseText = helpers.StringPtr(result.ResponseText)
+		msg.EventType = helpers.StringPtr(result.EventType)
+		meta["tool"] = result.Tool
+		if result.Widget != nil {
+			meta["widget"] = map[string]any{
+				"widget_type": result.Widget["widget_type"],
+				"widget_data": result.Widget["widget_data"],
+			}
+		}
+	}
+
+	if run.reused != nil {
+		meta["reused_answer"] = reusedAnswer(run.reused)
+	}
+
+	if run.rewrittenFrom != "" {
+		meta["original_query"] = run.rewrittenFrom
+		meta["rewritten_query"] = run.query
+	}
+
+	if len(related) > 0 {
+		meta["related_queries"] = related
+	}
+
+	var citations []citation.Citation
+	if run.citations != nil {
+		citations = run.citations.Citations()
+		meta["citations"] = citations
+		if invalid := run.citations.Invalid(); len(invalid) > 0 {
+			meta["invalid_citations"] = invalid
+		}
+	}
+
+	if runErr != nil && ctx.Err() != nil {
+		// Cancelled on request or abandoned by every client.
+		reason := context.Cause(ctx).Error()
+		meta["cancel_reason"] = reason
+		runErr = fmt.Errorf("generation cancelled: %s", reason)
+	}
+
+	if runErr != nil {
+		log.Printf("answer pipeline failed for message %s: %v", msg.ID, runErr)
+		msg.StreamStatus = helpers.StringPtr(constant.StreamStatusFailed)
+		meta["error"] = runErr.Error()
+	} else {
+		msg.StreamStatus = helpers.StringPtr(constant.StreamStatusDone)
+	}
+
+	if metaJSON, err := json.Marshal(meta); err == nil {
+		msg.MetaData = datatypes.JSON(metaJSON)
+	}
+
+	// The request context may already be gone; the outcome must still be stored.
+	saveErr := s.messageRepo.UpdateMessage(context.WithoutCancel(ctx), msg)
+	if saveErr != nil {
+		log.Printf("failed to update message %s: %v", msg.ID, saveErr)
+	} else if req.SaveVersion {
+		if _, saveErr = s.messageRepo.CreateVersion(context.WithoutCancel(ctx), msg); saveErr != nil {
+			log.Printf("failed to store version of message %s: %v", msg.ID, saveErr)
+		}
+		// A summary written from the previous answer no longer matches
+		// the thread.
+		if err := s.threadRepo.ResetHistorySummary(context.WithoutCancel(ctx), msg.ThreadID, msg.MessageIndex); err != nil {
+			log.Printf("failed to reset history summary of thread %s: %v", msg.ThreadID, err)
+		}
+	}
+
+	if err := s.usageRepo.RecordCalls(context.WithoutCancel(ctx), llmCalls(msg.ID, tool, usage.Calls())); err != nil {
+		log.Printf("failed to record LLM usage of message %s: %v", msg.ID, err)
+	}
+
+	run.Plan(constant.COTEnded)
+
+	end := map[string]any{"streaming": false, "stream_status": *msg.StreamStatus}
+	if citations != nil {
+		end["citations"] = citations
+	}
+	if runErr != nil {
+		end["error"] = runErr.Error()
+	}
+	run.Send(constant.EventEnd, end)
+
+	if runErr == nil && result != nil && run.reused == nil && run.req.CacheMode != llm.CacheBypass {
+		s.storeAnswer(context.WithoutCancel(ctx), run, result)
+	}
+
+	if runErr != nil {
+		return runErr
+	}
+	return saveErr
+}
+
+// run picks a tool for the query and executes it. A tool that cannot work
+// with what the query gave it falls back to the registry's default tool.
+func (s *answerServiceImpl) run(ctx context.Context, run *answerRun) (*tools.Result, error) {
+	if len(run.req.History) > 0 && s.rewriteTurns > 0 {
+		s.rewriteQuery(ctx, run)
+	}
+	query := run.query
+
+	run.Plan(constant.COTMakingToolDecision)
+
+	fallback := s.registry.Default()
+	if fallback == nil {
+		return nil, errors.New("no tools registered")
+	}
+
+	tool := fallback
+	params := map[string]any{}
+
+	detected, err := extract.ExtractToolType(ctx, query, s.registry.Catalog())
+	if err != nil {
+		log.Printf("tool detection failed, defaulting to %s: %v", fallback.Name(), err)
+	} else if t, ok := s.registry.Get(detected.Tool); ok {
+		tool = t
+		if detected.Params != nil {
+			params = detected.Params
+		}
+	} else {
+		log.Printf("detector chose unknown tool %q, defaulting to %s", detected.Tool, fallback.Name())
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if s.reusable(run, tool) && run.req.CacheMode == llm.CacheDefault {
+		cached, err := s.semanticCache.Lookup(ctx, tool.Name(), query)
+		if err != nil {
+			log.Printf("semantic cache lookup failed: %v", err)
+		} else if cached != nil {
+			return run.replay(cached)
+		}
+	}
+
+	inv := &tools.Invocation{
+		Query:         query,
+		Params:        params,
+		ClientIP:      run.req.ClientIP,
+		FilePaths:     run.filePaths(),
+		FileContext:   run.fileContext(),
+		PreviousChats: s.previousChats(ctx, run),
+		Emitter:       run,
+	}
+
+	result, err := tool.Execute(ctx, inv)
+	if errors.Is(err, tools.ErrNotApplicable) && tool != fallback {
+		log.Printf("%s cannot answer this query (%v), falling back to %s", tool.Name(), err, fallback.Name())
+		inv.Params = map[string]any{}
+		return fallback.Execute(ctx, inv)
+	}
+
+	return result, err
+}
+
+// rewriteQuery rewrites a follow-up from the last turns of the thread so
+// that tool detection and search understand it on its own. The rewrite is
+// shown in a PLAN event; a failed rewrite keeps the query as asked.
+func (s *answerServiceImpl) rewriteQuery(ctx context.Context, run *answerRun) {
+	turns := run.req.History
+	if len(turns) > s.rewriteTurns {
+		turns = turns[len(turns)-s.rewriteTurns:]
+	}
+
+	rewritten, err := extract.RewriteQuery(ctx, renderTurns(turns, rewriteAnswerRunes), run.query)
+	if err != nil {
+		log.Printf("query rewriting failed for message %s, keeping the query: %v", run.req.Message.ID, err)
+		return
+	}
+	run.rewrittenFrom = run.query
+	if strings.EqualFold(strings.TrimSpace(rewritten), strings.TrimSpace(run.query)) {
+		return
+	}
+
+	run.query = rewritten
+	run.PlanDetails(constant.COTRewritingQuery, map[string]any{
+		"original_query":  run.rewrittenFrom,
+		"rewritten_query": run.query,
+	})
+}
+
+// rewriteAnswerRunes is how much of each earlier answer the rewriter sees;
+// the gist is enough to resolve references.
+const rewriteAnswerRunes = 1000
+
+// reusable reports whether the answer of tool to this run's query may be
+// served from, or stored in, the semantic cache. The cache is shared by
+// every thread, so only the first message of a thread takes part: later
+// answers are written with the thread's earlier turns in the prompt.
+// Queries with files depend on more than their text and are left out too.
+func (s *answerServiceImpl) reusable(run *answerRun, tool tools.Tool) bool {
+	r, ok := tool.(tools.Reusable)
+	return ok && r.Reusable() && s.semanticCache != nil &&
+		len(run.req.Message.Files) == 0 && len(run.req.History) == 0
+}
+
+func (s *answerServiceImpl) storeAnswer(ctx context.Context, run *answerRun, result *tools.Result) {
+	tool, ok := s.registry.Get(result.Tool)
+	if !ok || !s.reusable(run, tool) || run.query == "" {
+		return
+	}
+
+	err := s.semanticCache.Store(ctx, &repositories.CachedAnswer{
+		Query:        run.query,
+		Tool:         result.Tool,
+		MessageID:    run.req.Message.ID,
+		ResponseText: result.ResponseText,
+		EventType:    result.EventType,
+		WebResults:   run.webResults,
+		Widget:       result.Widget,
+		CreatedAt:    time.Now(),
+	})
+	if err != nil {
+		log.Printf("failed to store answer of message %s in the semantic cache: %v", run.req.Message.ID, err)
+	}
+}
+
+// replay streams a cached answer in place of running its tool.
+func (r *answerRun) replay(cached *repositories.CachedAnswer) (*tools.Result, error) {
+	r.reused = cached
+
+	if err := r.PlanDetails(constant.COTReusingAnswer, map[string]any{
+		"reused_answer": reusedAnswer(cached),
+	}); err != nil {
+		return nil, err
+	}
+	if len(cached.WebResults) > 0 {
+		if err := r.Send(constant.EventWebResults, cached.WebResults); err != nil {
+			return nil, err
+		}
+	}
+	text := cached.ResponseText
+	if filter := r.citationFilter(); filter != nil {
+		text = filter.Write(text) + filter.Flush()
+	}
+	if err := r.Send(constant.EventMarkdownAnswer, map[string]any{
+		"chunk":     text,
+		"streaming": true,
+	}); err != nil {
+		return nil, err
+	}
+	if cached.Widget != nil {
+		if err := r.Send(constant.EventWidget, cached.Widget); err != nil {
+			return nil, err
+		}
+	}
+
+	return &tools.Result{
+		Tool:         cached.Tool,
+		EventType:    cached.EventType,
+		ResponseText: text,
+		Widget:       cached.Widget,
+	}, nil
+}
+
+// relatedQueries suggests follow-up questions to the answer and streams
+// them as a RELATED_QUERIES event. Like takeaways they are a nice-to-have:
+// failures are only logged.
+func (r *answerRun) relatedQueries(ctx context.Context, result *tools.Result) []string {
+	query := r.query
+
+	sources := "None."
+	if r.webResults != nil {
+		var b strings.Builder
+		for _, src := range citation.SourcesFromWebResults(r.webResults) {
+			fmt.Fprintf(&b, "[%d] %s\n", src.Index, src.Title)
+		}
+		if b.Len() > 0 {
+			sources = strings.TrimSpace(b.String())
+		}
+	}
+
+	questions, err := extract.ExtractRelatedQueries(ctx, query, sources, result.ResponseText)
+	if err != nil {
+		log.Printf("related query generation failed for message %s: %v", r.req.Message.ID, err)
+		return nil
+	}
+	if len(questions) == 0 {
+		return nil
+	}
+
+	if err := r.Send(constant.EventRelatedQueries, map[string]any{
+		"version":   eventVersion,
+		"queries":   questions,
+		"streaming": true,
+	}); err != nil {
+		log.Printf("failed to send related queries of message %s: %v", r.req.Message.ID, err)
+	}
+	return questions
+}
+
+// reusedAnswer describes a reused answer in PLAN events and metadata.
+func reusedAnswer(cached *repositories.CachedAnswer) map[string]any {
+	return map[string]any{
+		"query":       cached.Query,
+		"message_id":  cached.MessageID,
+		"similarity":  cached.Similarity,
+		"answered_at": cached.CreatedAt,
+	}
+}
+
+// answerStages are the stages whose output is the answer the user reads.
+var answerStages = map[string]bool{
+	constant.StageSynthesis:       true,
+	constant.StageWeatherSummary:  true,
+	constant.StageBusinessSummary: true,
+	constant.StageYoutubeSummary:  true,
+}
+
+// answerModel returns the model that wrote the answer, i.e. of the last
+// answer-stage call, or "" if no such call was made.
+func answerModel(calls []llm.Call) string {
+	for i := len(calls) - 1; i >= 0; i-- {
+		if answerStages[calls[i].Stage] {
+			return calls[i].Model
+		}
+	}
+	return ""
+}
+
+// llmCalls converts the recorded calls of a run into rows for messageID.
+// Calls answered from the cache were not billed and are left out.
+func llmCalls(messageID uuid.UUID, tool string, calls []llm.Call) []models.LLMCall {
+	rows := make([]models.LLMCall, 0, len(calls))
+	for _, c := range calls {
+		if c.Cached {
+			continue
+		}
+		rows = append(rows, models.LLMCall{
+			MessageID:   messageID,
+			Stage:       c.Stage,
+			Model:       c.Model,
+			Tool:        tool,
+			InputToken:  c.InputTokens,
+			OutputToken: c.OutputTokens,
+			LatencyMS:   c.LatencyMS,
+			Cost:        c.Cost,
+			CreatedAt:   c.StartedAt,
+		})
+	}
+	return rows
+}
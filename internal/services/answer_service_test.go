@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"agios/internal/config"
+	"agios/internal/models"
+	"agios/internal/repositories"
+	"agios/internal/tools"
+	"agios/internal/utils/citation"
+	"agios/internal/utils/constant"
+	"agios/internal/utils/helpers"
+	"agios/internal/utils/llm"
+	"agios/internal/utils/reader"
+	"agios/internal/utils/search"
+
+	"github.com/google/uuid"
+)
+
+// pipelineScript answers every stage of a general search on the fake
+// provider. The synthesis, as the only unmatched generation, gets Default.
+var pipelineScript = llm.FakeScript{
+	Rules: []llm.FakeRule{
+		{Match: "selecting the appropriate tool", Call: &llm.FunctionCall{Name: constant.ToolGeneralSearch, Args: map[string]any{}}},
+		{Match: "Extract the main specific search terms", Reply: `{"search_term": ["capital of France"]}`},
+		{Match: "structured information extractor", Reply: `{"key_takeaways": [{"text": "Paris is the capital.", "confidence_score": 0.9}], "related_search_terms": ["Paris"], "short_summary": "Paris.", "metrics": []}`},
+		{Match: "You suggest the follow-up questions", Reply: `{"questions": ["How big is Paris?", "When did Paris become the capital?", "What is Paris known for?"]}`},
+	},
+	Default: "Paris is the capital of France [1]. It is also on the Moon [7].",
+}
+
+type recordedEvent struct {
+	name string
+	data map[string]any
+}
+
+type recordingSender struct {
+	events []recordedEvent
+}
+
+func (s *recordingSender) SendEvent(event string, data string) error {
+	var payload map[string]any
+	if err := json.Unmarshal([]byte(data), &payload); err != nil {
+		return err
+	}
+	s.events = append(s.events, recordedEvent{name: event, data: payload})
+	return nil
+}
+
+type stubMessageRepo struct {
+	repositories.MessageRepository
+	saved *models.Message
+}
+
+func (r *stubMessageRepo) UpdateMessage(ctx context.Context, message *models.Message) error {
+	saved := *message
+	r.saved = &saved
+	return nil
+}
+
+type stubUsageRepo struct {
+	repositories.UsageRepository
+	calls []models.LLMCall
+}
+
+func (r *stubUsageRepo) RecordCalls(ctx context.Context, calls []models.LLMCall) error {
+	r.calls = append(r.calls, calls...)
+	return nil
+}
+
+// setupPipeline points the llm, search and reader packages at the fake
+// provider and a SearXNG stub.
+func setupPipeline(t *testing.T) *config.Config {
+	t.Helper()
+
+	searxng := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"results": []map[string]any{
+			{"title": "Paris - Wikipedia", "url": "https://en.wikipedia.org/wiki/Paris", "content": "Paris is the capital of France."},
+			{"title": "France", "url": "https://example.org/france", "content": "France is a country in Europe."},
+		}})
+	}))
+	t.Cleanup(searxng.Close)
+
+	script, err := json.Marshal(pipelineScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scriptPath := filepath.Join(t.TempDir(), "script.json")
+	if err := os.WriteFile(scriptPath, script, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		LLMProvider:       "fake",
+		FakeLLMScript:     scriptPath,
+		CurrentLLMModel:   "fake-model",
+		SearXNGURL:        searxng.URL,
+		SearchProviders:   []string{"searxng"},
+		SearchMaxResults:  10,
+		SearchTimeout:     5 * time.Second,
+		SearchConcurrency: 2,
+		CitationMode:      citation.ModeDrop,
+	}
+	if err := llm.Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+	search.Init(cfg)
+	reader.Init(cfg)
+	return cfg
+}
+
+func TestAnswerGeneralSearch(t *testing.T) {
+	cfg := setupPipeline(t)
+
+	messages := &stubMessageRepo{}
+	usage := &stubUsageRepo{}
+	svc := NewAnswerService(messages, nil, usage, nil, tools.NewDefaultRegistry(), cfg)
+
+	msg := &models.Message{
+		ID:           uuid.New(),
+		ThreadID:     uuid.New(),
+		QueryText:    helpers.StringPtr("What is the capital of France?"),
+		StreamStatus: helpers.StringPtr(constant.StreamStatusInProgress),
+	}
+	events := &recordingSender{}
+	if err := svc.Answer(context.Background(), AnswerRequest{Message: msg}, events); err != nil {
+		t.Fatalf("Answer: %v", err)
+	}
+
+	// Every event kind arrives, in pipeline order.
+	var order []string
+	var answer strings.Builder
+	byName := map[string]map[string]any{}
+	for _, e := range events.events {
+		if len(order) == 0 || order[len(order)-1] != e.name {
+			order = append(order, e.name)
+		}
+		if e.name == constant.EventMarkdownAnswer {
+			answer.WriteString(e.data["chunk"].(string))
+		}
+		byName[e.name] = e.data
+	}
+	want := []string{
+		constant.EventStart,
+		constant.EventPlan,
+		constant.EventWebResults,
+		constant.EventPlan,
+		constant.EventMarkdownAnswer,
+		constant.EventWidget,
+		constant.EventRelatedQueries,
+		constant.EventPlan,
+		constant.EventEnd,
+	}
+	if strings.Join(order, " ") != strings.Join(want, " ") {
+		t.Fatalf("events = %v, want %v", order, want)
+	}
+
+	if results := byName[constant.EventWebResults]["results"].([]any); len(results) != 2 {
+		t.Errorf("web results = %d, want 2", len(results))
+	}
+
+	wantAnswer := "Paris is the capital of France [1]. It is also on the Moon ."
+	if answer.String() != wantAnswer {
+		t.Errorf("answer = %q, want %q", answer.String(), wantAnswer)
+	}
+
+	if got := byName[constant.EventWidget]["widget_type"]; got != constant.WidgetSynthResults {
+		t.Errorf("widget type = %v, want %s", got, constant.WidgetSynthResults)
+	}
+	if queries := byName[constant.EventRelatedQueries]["queries"].([]any); len(queries) != 3 {
+		t.Errorf("related queries = %v, want 3", queries)
+	}
+
+	end := byName[constant.EventEnd]
+	if end["stream_status"] != constant.StreamStatusDone {
+		t.Errorf("end status = %v, want %s", end["stream_status"], constant.StreamStatusDone)
+	}
+	if citations, _ := end["citations"].([]any); len(citations) != 1 {
+		t.Errorf("end citations = %v, want one", end["citations"])
+	}
+
+	saved := messages.saved
+	if saved == nil {
+		t.Fatal("message was not saved")
+	}
+	if *saved.StreamStatus != constant.StreamStatusDone || *saved.ResponseText != wantAnswer {
+		t.Errorf("saved status %q text %q", *saved.StreamStatus, *saved.ResponseText)
+	}
+	var meta map[string]any
+	if err := json.Unmarshal(saved.MetaData, &meta); err != nil {
+		t.Fatal(err)
+	}
+	if meta["tool"] != constant.ToolGeneralSearch {
+		t.Errorf("meta tool = %v, want %s", meta["tool"], constant.ToolGeneralSearch)
+	}
+	if invalid, _ := meta["invalid_citations"].([]any); len(invalid) != 1 || invalid[0] != float64(7) {
+		t.Errorf("invalid citations = %v, want [7]", meta["invalid_citations"])
+	}
+	if len(usage.calls) == 0 {
+		t.Error("no LLM calls were recorded")
+	}
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"agios/internal/config"
+	"agios/internal/repositories"
+	"agios/internal/utils/llm"
+)
+
+// BudgetPeriod is the spend against one budget.
+type BudgetPeriod struct {
+	BudgetUSD float64   `json:"budget_usd"` // 0 means no cap
+	SpentUSD  float64   `json:"spent_usd"`
+	Since     time.Time `json:"since"`
+	Exceeded  bool      `json:"exceeded"`
+}
+
+// BudgetStatus is the spend against the daily and monthly budgets.
+type BudgetStatus struct {
+	Daily         BudgetPeriod `json:"daily"`
+	Monthly       BudgetPeriod `json:"monthly"`
+	Action        string       `json:"action"`
+	FallbackModel string       `json:"fallback_model,omitempty"`
+}
+
+// Exceeded reports whether any budget is spent.
+func (s BudgetStatus) Exceeded() bool {
+	return s.Daily.Exceeded || s.Monthly.Exceeded
+}
+
+// BudgetService enforces the configured LLM spend budgets.
+type BudgetService interface {
+	// Admit decides how a new request for model may run. It returns the
+	// model to use, which is the fallback model once a budget is spent and
+	// the action is downgrade, or ErrBudgetExceeded if the action is reject.
+	// While a budget is set, a model without a listed price, whose spend
+	// could not be counted, fails with ErrModelNotPriced.
+	Admit(ctx context.Context, model string) (string, error)
+	Status(ctx context.Context) (*BudgetStatus, error)
+}
+
+// Error definitions
+var (
+	ErrBudgetExceeded = fmt.Errorf("BUDGET_EXCEEDED")
+	ErrModelNotPriced = fmt.Errorf("MODEL_NOT_PRICED")
+)
+
+// NewBudgetService constructs a BudgetService.
+func NewBudgetService(usageRepo repositories.UsageRepository, cfg *config.Config) BudgetService {
+	return &budgetServiceImpl{usageRepo: usageRepo, cfg: cfg}
+}
+
+type budgetServiceImpl struct {
+	usageRepo repositories.UsageRepository
+	cfg       *config.Config
+}
+
+func (s *budgetServiceImpl) Status(ctx context.Context) (*BudgetStatus, error) {
+	cfg := s.cfg
+	now := time.Now().UTC()
+	status := &BudgetStatus{
+		Daily: BudgetPeriod{
+			BudgetUSD: cfg.DailyBudgetUSD,
+			Since:     time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC),
+		},
+		Monthly: BudgetPeriod{
+			BudgetUSD: cfg.MonthlyBudgetUSD,
+			Since:     time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC),
+		},
+		Action: cfg.BudgetAction,
+	}
+	if cfg.BudgetAction == config.BudgetActionDowngrade {
+		status.FallbackModel = cfg.BudgetFallbackModel
+	}
+
+	for _, p := range []*BudgetPeriod{&status.Daily, &status.Monthly} {
+		spent, err := s.usageRepo.SpendSince(ctx, p.Since)
+		if err != nil {
+			return nil, err
+		}
+		p.SpentUSD = spent
+		p.Exceeded = p.BudgetUSD > 0 && spent >= p.BudgetUSD
+	}
+
+	return status, nil
+}
+
+func (s *budgetServiceImpl) Admit(ctx context.Context, model string) (string, error) {
+	if s.cfg.DailyBudgetUSD <= 0 && s.cfg.MonthlyBudgetUSD <= 0 {
+		return model, nil
+	}
+
+	status, err := s.Status(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !status.Exceeded() {
+		return model, s.checkPriced(model)
+	}
+
+	if status.Action == config.BudgetActionDowngrade {
+		log.Printf("LLM budget spent (daily %.4f/%.4f, monthly %.4f/%.4f USD), downgrading to %s",
+			status.Daily.SpentUSD, status.Daily.BudgetUSD, status.Monthly.SpentUSD, status.Monthly.BudgetUSD, status.FallbackModel)
+		return status.FallbackModel, s.checkPriced(status.FallbackModel)
+	}
+	return "", ErrBudgetExceeded
+}
+
+// checkPriced fails with ErrModelNotPriced if model, or with no model any
+// model routed to a stage, has no price to count against the budgets.
+func (s *budgetServiceImpl) checkPriced(model string) error {
+	models := []string{model}
+	if model == "" {
+		models = []string{s.cfg.CurrentLLMModel}
+		for _, m := range s.cfg.StageModels {
+			models = append(models, m)
+		}
+	}
+
+	for _, m := range models {
+		if !llm.Priced(m) {
+			return fmt.Errorf("%w: %s", ErrModelNotPriced, m)
+		}
+	}
+	return nil
+}
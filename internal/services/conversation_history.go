@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"agios/internal/models"
+	"agios/internal/utils/citation"
+	"agios/internal/utils/constant"
+	extract "agios/internal/utils/extract"
+	"agios/internal/utils/helpers"
+)
+
+// summaryShare is the part of the history budget, 1/summaryShare, left to
+// the running summary once earlier turns no longer fit verbatim.
+const summaryShare = 4
+
+// minAnswerRunes is the least of the newest answer kept when it alone is
+// over the budget.
+const minAnswerRunes = 500
+
+// estimateTokens approximates the tokens of text at four characters a token,
+// close enough for budgeting across models.
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// conversationTurns returns the answered messages of history, oldest first,
+// reduced to what the model sees again: the query and the answer without
+// its citation markers, whose sources are gone. Events, versions and
+// metadata, which hold the web results and widget data of earlier answers,
+// are dropped.
+func conversationTurns(history []models.Message) []models.Message {
+	var turns []models.Message
+	for _, m := range history {
+		if m.QueryText == nil || m.StreamStatus == nil || *m.StreamStatus != constant.StreamStatusDone {
+			continue
+		}
+		turn := models.Message{
+			ID:           m.ID,
+			ThreadID:     m.ThreadID,
+			MessageIndex: m.MessageIndex,
+			QueryText:    m.QueryText,
+			StreamStatus: m.StreamStatus,
+		}
+		if m.ResponseText != nil {
+			turn.ResponseText = helpers.StringPtr(strings.TrimSpace(citation.Strip(*m.ResponseText)))
+		}
+		turns = append(turns, turn)
+	}
+	return turns
+}
+
+// previousChats renders the earlier turns of the thread for the
+// previous_chats_data prompt slot within the history budget of the
+// answering model. The newest turns are kept verbatim; the older ones are
+// replaced by the running summary of the thread.
+func (s *answerServiceImpl) previousChats(ctx context.Context, run *answerRun) string {
+	turns := run.req.History
+	if len(turns) == 0 {
+		return "None."
+	}
+
+	budget := s.cfg.HistoryBudget(s.historyModel(run))
+	all := renderTurns(turns, 0)
+	if estimateTokens(all) <= budget {
+		return all
+	}
+
+	// Walk back from the newest turn while the turns fit next to the summary.
+	verbatimBudget := budget - budget/summaryShare
+	keep, used := len(turns), 0
+	for keep > 0 {
+		cost := estimateTokens(renderTurns(turns[keep-1:keep], 0))
+		if used+cost > verbatimBudget {
+			break
+		}
+		used += cost
+		keep--
+	}
+
+	var verbatim string
+	if keep == len(turns) {
+		// The newest turn alone is over the budget: keep it with its answer cut.
+		newest := turns[len(turns)-1:]
+		maxAnswer := max((verbatimBudget-estimateTokens(*newest[0].QueryText))*4, minAnswerRunes)
+		verbatim = renderTurns(newest, maxAnswer)
+		keep--
+	} else {
+		verbatim = renderTurns(turns[keep:], 0)
+	}
+
+	summary := ""
+	if keep > 0 {
+		summary = s.historySummary(ctx, run, turns[:keep], budget/summaryShare)
+	}
+	if summary == "" {
+		return verbatim
+	}
+	return "Summary of the earlier conversation: " + summary + "\n\n" + verbatim
+}
+
+// historyModel returns the model that writes the answer, whose budget
+// applies.
+func (s *answerServiceImpl) historyModel(run *answerRun) string {
+	if run.req.Model != "" {
+		return run.req.Model
+	}
+	return s.cfg.ModelForStage(constant.StageSynthesis)
+}
+
+// historySummary returns the running summary of older, the turns that no
+// longer fit verbatim, in about budget tokens. The summary stored on the
+// thread is extended with the turns it does not cover yet, a batch at a
+// time, and stored again. A failed update keeps what was summarized so far;
+// an empty result leaves the older turns out.
+func (s *answerServiceImpl) historySummary(ctx context.Context, run *answerRun, older []models.Message, budget int) string {
+	threadID := run.req.Message.ThreadID
+	thread, err := s.threadRepo.GetThread(ctx, threadID)
+	if err != nil {
+		log.Printf("loading the history summary of thread %s failed: %v", threadID, err)
+		return ""
+	}
+
+	summary, pending := "", older
+	// A summary that reaches into the verbatim turns, as when an earlier
+	// message is regenerated, cannot be used; the older turns are
+	// summarized anew.
+	last := older[len(older)-1].MessageIndex
+	if thread.HistorySummary != nil && thread.SummaryThroughIndex != nil && *thread.SummaryThroughIndex <= last {
+		summary = *thread.HistorySummary
+		through := *thread.SummaryThroughIndex
+		pending = nil
+		for _, m := range older {
+			if m.MessageIndex > through {
+				pending = append(pending, m)
+			}
+		}
+	}
+
+	maxWords := budget * 3 / 4
+	through := -1
+	for len(pending) > 0 {
+		batch := summaryBatch(pending, s.cfg.HistoryBudget(s.historyModel(run)))
+		updated, err := extract.SummarizeConversation(ctx, summary, renderTurns(batch, 0), maxWords)
+		if err != nil {
+			log.Printf("summarizing the history of thread %s failed: %v", threadID, err)
+			break
+		}
+		summary, through = updated, batch[len(batch)-1].MessageIndex
+		pending = pending[len(batch):]
+	}
+
+	if through >= 0 {
+		if err := s.threadRepo.UpdateHistorySummary(ctx, threadID, summary, through); err != nil {
+			log.Printf("storing the history summary of thread %s failed: %v", threadID, err)
+		}
+	}
+	return summary
+}
+
+// summaryBatch returns the leading turns of pending that fit in budget
+// tokens, and at least one, so a summary call never outgrows the context
+// the turns were cut for.
+func summaryBatch(pending []models.Message, budget int) []models.Message {
+	n, used := 0, 0
+	for n < len(pending) {
+		used += estimateTokens(renderTurns(pending[n:n+1], 0))
+		if n > 0 && used > budget {
+			break
+		}
+		n++
+	}
+	return pending[:n]
+}
@@ -0,0 +1,513 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"agios/internal/models"
+	"agios/internal/repositories"
+	"agios/internal/utils/constant"
+	"agios/internal/utils/helpers"
+	"agios/internal/utils/llm"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// Stream timings; tests shorten them.
+var (
+	// streamReadBlock is how long a subscriber waits for new events before it
+	// checks whether generation is still going.
+	streamReadBlock = 5 * time.Second
+	// abandonGrace is how long a job keeps running once its last client has
+	// disconnected, leaving time to reconnect with Last-Event-ID.
+	abandonGrace = 30 * time.Second
+)
+
+// DefaultCancelReason is recorded when a cancellation gives no reason.
+const DefaultCancelReason = "Cancelled by user."
+
+// errAbandoned is the cancellation cause of a job nobody is listening to.
+var errAbandoned = errors.New("every client disconnected")
+
+// EventIDSender delivers a server-sent event with an id: field.
+// sse.SSEWriter satisfies it.
+type EventIDSender interface {
+	SendEventWithID(id int64, event string, data string) error
+}
+
+// GenerationService runs the answer pipeline as a background job keyed by
+// message ID. Every event is appended to the message's event stream, so a
+// client can drop and reconnect without losing the answer.
+type GenerationService interface {
+	// Start runs the pipeline for req in the background.
+	Start(req AnswerRequest)
+	// Resumable reports whether the events of a message can still be streamed.
+	Resumable(ctx context.Context, messageID uuid.UUID) (bool, error)
+	// Stream writes the events of a message after lastEventID to w and then
+	// follows the live tail until END is sent or ctx is done. A job whose
+	// clients have all gone away is cancelled after a grace period.
+	Stream(ctx context.Context, messageID uuid.UUID, lastEventID int64, w EventIDSender) error
+	// Regenerate re-runs the pipeline for a finished message, keeping the
+	// previous answers as versions. A model answers every stage; without one
+	// the stages use their routed models. Cached responses are not reused
+	// unless cacheMode says otherwise. The new answer is streamed like any
+	// other.
+	Regenerate(ctx context.Context, messageID uuid.UUID, model string, clientIP string, cacheMode llm.CacheMode) (*models.Message, error)
+	// Cancel stops the generation of a message and returns it marked FAILED
+	// with reason.
+	Cancel(ctx context.Context, messageID uuid.UUID, reason string) (*models.Message, error)
+}
+
+// Error definitions
+var (
+	ErrStreamNotFound       = fmt.Errorf("STREAM_NOT_FOUND")
+	ErrMessageNotInProgress = fmt.Errorf("MESSAGE_NOT_IN_PROGRESS")
+	ErrMessageInProgress    = fmt.Errorf("MESSAGE_IN_PROGRESS")
+)
+
+// NewGenerationService constructs a GenerationService.
+func NewGenerationService(answerService AnswerService, eventRepo repositories.EventStreamRepository, messageRepo repositories.MessageRepository, messageEventRepo repositories.MessageEventRepository) GenerationService {
+	return &generationServiceImpl{
+		answers:       answerService,
+		events:        eventRepo,
+		messages:      messageRepo,
+		messageEvents: messageEventRepo,
+		jobs:          map[uuid.UUID]*generationJob{},
+	}
+}
+
+type generationServiceImpl struct {
+	answers       AnswerService
+	events        repositories.EventStreamRepository
+	messages      repositories.MessageRepository
+	messageEvents repositories.MessageEventRepository
+
+	mu   sync.Mutex
+	jobs map[uuid.UUID]*generationJob
+}
+
+// generationJob is a pipeline run in progress on this instance. Its fields
+// other than cancel and done are guarded by generationServiceImpl.mu.
+type generationJob struct {
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+
+	subscribers int
+	abandon     *time.Timer
+}
+
+// streamSender numbers events and appends them to the message's event
+// stream. It also persists them; answer chunks are buffered and written with
+// the next event of another type.
+type streamSender struct {
+	mu           sync.Mutex
+	repo         repositories.EventStreamRepository
+	persist      repositories.MessageEventRepository
+	messageID    uuid.UUID
+	versionIndex int
+	seq          int64
+	pending      []models.MessageEvent
+}
+
+func (s *streamSender) SendEvent(event string, data string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	s.pending = append(s.pending, models.MessageEvent{
+		MessageID:    s.messageID,
+		VersionIndex: s.versionIndex,
+		Sequence:     s.seq,
+		EventType:    event,
+		Payload:      datatypes.JSON(data),
+		CreatedAt:    time.Now(),
+	})
+	if event != constant.EventMarkdownAnswer {
+		s.flushLocked()
+	}
+
+	return s.repo.Append(context.Background(), s.messageID, repositories.StreamedEvent{
+		Seq:   s.seq,
+		Event: event,
+		Data:  data,
+	})
+}
+
+// flush persists the buffered events.
+func (s *streamSender) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.flushLocked()
+}
+
+func (s *streamSender) flushLocked() {
+	if len(s.pending) == 0 {
+		return
+	}
+	// A failed write loses the replay of a finished answer, not the answer.
+	if err := s.persist.CreateEvents(context.Background(), s.pending); err != nil {
+		log.Printf("failed to persist %d events of message %s: %v", len(s.pending), s.messageID, err)
+	}
+	s.pending = nil
+}
+
+func (s *generationServiceImpl) Start(req AnswerRequest) {
+	messageID := req.Message.ID
+
+	// The job outlives the request that started it; it ends on Cancel or
+	// when it has been abandoned by every client.
+	ctx, cancel := context.WithCancelCause(context.Background())
+	job := &generationJob{cancel: cancel, done: make(chan struct{})}
+
+	s.mu.Lock()
+	s.jobs[messageID] = job
+	s.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			if s.jobs[messageID] == job {
+				delete(s.jobs, messageID)
+			}
+			if job.abandon != nil {
+				job.abandon.Stop()
+			}
+			s.mu.Unlock()
+			cancel(context.Canceled)
+			close(job.done)
+		}()
+
+		sender := &streamSender{
+			repo:         s.events,
+			persist:      s.messageEvents,
+			messageID:    messageID,
+			versionIndex: req.VersionIndex,
+		}
+
+		if err := s.answer(ctx, req, sender); err != nil {
+			log.Printf("answer job for message %s failed: %v", messageID, err)
+		}
+		sender.flush()
+
+		if err := s.events.Finish(context.WithoutCancel(ctx), messageID); err != nil {
+			log.Printf("failed to set retention of event stream for message %s: %v", messageID, err)
+		}
+	}()
+}
+
+// answer runs the pipeline for req. A panic fails the message like any
+// other error, so neither the message nor its clients wait for an END that
+// never comes.
+func (s *generationServiceImpl) answer(ctx context.Context, req AnswerRequest, sender EventSender) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		log.Printf("answer job for message %s panicked: %v\n%s", req.Message.ID, r, debug.Stack())
+		err = fmt.Errorf("generation failed: %v", r)
+		s.fail(context.WithoutCancel(ctx), req.Message.ID, err, sender)
+	}()
+	return s.answers.Answer(ctx, req, sender)
+}
+
+// fail marks a message still in progress FAILED with cause and sends the END
+// event its clients are waiting for.
+func (s *generationServiceImpl) fail(ctx context.Context, messageID uuid.UUID, cause error, sender EventSender) {
+	msg, err := s.messages.GetMessage(ctx, messageID)
+	if err != nil {
+		log.Printf("failed to load message %s to mark it failed: %v", messageID, err)
+	} else if msg.StreamStatus == nil || *msg.StreamStatus != constant.StreamStatusInProgress {
+		// The answer was stored and END sent before things went wrong.
+		return
+	} else {
+		meta := map[string]any{}
+		if len(msg.MetaData) > 0 {
+			_ = json.Unmarshal(msg.MetaData, &meta)
+		}
+		meta["error"] = cause.Error()
+		if metaJSON, err := json.Marshal(meta); err == nil {
+			msg.MetaData = datatypes.JSON(metaJSON)
+		}
+		msg.StreamStatus = helpers.StringPtr(constant.StreamStatusFailed)
+		if err := s.messages.UpdateMessage(ctx, msg); err != nil {
+			log.Printf("failed to mark message %s failed: %v", messageID, err)
+		}
+	}
+
+	end, _ := json.Marshal(map[string]any{
+		"streaming":     false,
+		"stream_status": constant.StreamStatusFailed,
+		"error":         cause.Error(),
+	})
+	if err := sender.SendEvent(constant.EventEnd, string(end)); err != nil {
+		log.Printf("failed to send END of message %s: %v", messageID, err)
+	}
+}
+
+func (s *generationServiceImpl) isRunning(messageID uuid.UUID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.jobs[messageID]
+	return ok
+}
+
+// subscribe registers a client of the job for messageID, if it runs here.
+// The returned func unregisters it; the last client to leave starts the
+// abandon timer.
+func (s *generationServiceImpl) subscribe(messageID uuid.UUID) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[messageID]
+	if !ok {
+		return func() {}
+	}
+	job.subscribers++
+	if job.abandon != nil {
+		job.abandon.Stop()
+		job.abandon = nil
+	}
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		job.subscribers--
+		if job.subscribers == 0 && s.jobs[messageID] == job {
+			job.abandon = time.AfterFunc(abandonGrace, func() {
+				job.cancel(errAbandoned)
+			})
+		}
+	}
+}
+
+// isFinished reports whether the stored message is no longer in progress.
+func (s *generationServiceImpl) isFinished(ctx context.Context, messageID uuid.UUID) bool {
+	if s.isRunning(messageID) {
+		return false
+	}
+	msg, err := s.messages.GetMessage(ctx, messageID)
+	if err != nil {
+		return true
+	}
+	return msg.StreamStatus == nil || *msg.StreamStatus != constant.StreamStatusInProgress
+}
+
+func (s *generationServiceImpl) Resumable(ctx context.Context, messageID uuid.UUID) (bool, error) {
+	if s.isRunning(messageID) {
+		return true, nil
+	}
+	return s.events.Exists(ctx, messageID)
+}
+
+func (s *generationServiceImpl) Stream(ctx context.Context, messageID uuid.UUID, lastEventID int64, w EventIDSender) error {
+	ok, err := s.Resumable(ctx, messageID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrStreamNotFound
+	}
+
+	defer s.subscribe(messageID)()
+
+	after := lastEventID
+	draining := false
+	for {
+		block := streamReadBlock
+		if draining {
+			block = time.Millisecond
+		}
+
+		events, err := s.events.Read(ctx, messageID, after, block)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		for _, e := range events {
+			if err := w.SendEventWithID(e.Seq, e.Event, e.Data); err != nil {
+				return err
+			}
+			after = e.Seq
+			if e.Event == constant.EventEnd {
+				return nil
+			}
+		}
+
+		if len(events) > 0 {
+			continue
+		}
+
+		// No END and nothing new. If the job has already finished (or died
+		// with its instance), drain once more and stop following.
+		if draining {
+			return nil
+		}
+		draining = s.isFinished(ctx, messageID)
+	}
+}
+
+func (s *generationServiceImpl) Cancel(ctx context.Context, messageID uuid.UUID, reason string) (*models.Message, error) {
+	if reason == "" {
+		reason = DefaultCancelReason
+	}
+
+	s.mu.Lock()
+	job, ok := s.jobs[messageID]
+	s.mu.Unlock()
+
+	if ok {
+		// The job records the outcome itself and sends END to its clients.
+		job.cancel(errors.New(reason))
+		select {
+		case <-job.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		msg, err := s.messages.GetMessage(ctx, messageID)
+		if err != nil {
+			return nil, err
+		}
+		if msg.StreamStatus == nil || *msg.StreamStatus != constant.StreamStatusFailed {
+			// It finished before the cancellation took effect.
+			return nil, ErrMessageNotInProgress
+		}
+		return msg, nil
+	}
+
+	msg, err := s.messages.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.StreamStatus == nil || *msg.StreamStatus != constant.StreamStatusInProgress {
+		return nil, ErrMessageNotInProgress
+	}
+
+	// No job runs here for a message still in progress: its instance went
+	// away. Close it out so readers stop waiting for it.
+	meta := map[string]any{}
+	if len(msg.MetaData) > 0 {
+		_ = json.Unmarshal(msg.MetaData, &meta)
+	}
+	meta["error"] = "generation cancelled: " + reason
+	meta["cancel_reason"] = reason
+	if metaJSON, err := json.Marshal(meta); err == nil {
+		msg.MetaData = datatypes.JSON(metaJSON)
+	}
+	msg.StreamStatus = helpers.StringPtr(constant.StreamStatusFailed)
+
+	if err := s.messages.UpdateMessage(ctx, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (s *generationServiceImpl) Regenerate(ctx context.Context, messageID uuid.UUID, model string, clientIP string, cacheMode llm.CacheMode) (*models.Message, error) {
+	if s.isRunning(messageID) {
+		return nil, ErrMessageInProgress
+	}
+
+	msg, err := s.messages.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.StreamStatus != nil && *msg.StreamStatus == constant.StreamStatusInProgress {
+		return nil, ErrMessageInProgress
+	}
+
+	// Claim the message so that concurrent requests cannot both regenerate
+	// it. Until the job starts, a failure hands the message back as it was.
+	claimed, err := s.messages.ClaimMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrMessageInProgress
+	}
+	previous := *msg
+	started := false
+	defer func() {
+		if started {
+			return
+		}
+		if err := s.messages.UpdateMessage(context.WithoutCancel(ctx), &previous); err != nil {
+			log.Printf("failed to release message %s: %v", messageID, err)
+		}
+	}()
+
+	// The original answer becomes version 0 the first time round.
+	count, err := s.messages.CountVersions(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		if _, err := s.messages.CreateVersion(ctx, msg); err != nil {
+			return nil, err
+		}
+		count = 1
+	}
+
+	history, err := s.messages.GetThreadHistory(ctx, msg.ThreadID, msg.MessageIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the attached files survive from the previous answer's metadata.
+	meta := map[string]any{}
+	if len(msg.MetaData) > 0 {
+		_ = json.Unmarshal(msg.MetaData, &meta)
+	}
+	fresh := map[string]any{}
+	if ids, ok := meta["file_ids"]; ok {
+		fresh["file_ids"] = ids
+	}
+	metaJSON, _ := json.Marshal(fresh)
+
+	if model != "" {
+		msg.Model = model
+	}
+	msg.ResponseText = nil
+	msg.EventType = helpers.StringPtr(constant.EventStart)
+	msg.InputToken = 0
+	msg.OutputToken = 0
+	msg.ResponseTime = 0
+	msg.Cost = 0
+	msg.StreamStatus = helpers.StringPtr(constant.StreamStatusInProgress)
+	msg.MetaData = datatypes.JSON(metaJSON)
+
+	// Event IDs start over with the new answer.
+	if err := s.events.Delete(ctx, messageID); err != nil {
+		return nil, err
+	}
+
+	if err := s.messages.UpdateMessage(ctx, msg); err != nil {
+		return nil, err
+	}
+
+	started = true
+	s.Start(AnswerRequest{
+		Message:      msg,
+		History:      history,
+		ClientIP:     clientIP,
+		Model:        model,
+		CacheMode:    cacheMode,
+		SaveVersion:  true,
+		VersionIndex: int(count),
+	})
+
+	return msg, nil
+}
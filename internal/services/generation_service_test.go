@@ -0,0 +1,360 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"agios/internal/models"
+	"agios/internal/repositories"
+	"agios/internal/utils/constant"
+	"agios/internal/utils/helpers"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// memMessageRepo keeps messages in memory.
+type memMessageRepo struct {
+	repositories.MessageRepository
+
+	mu       sync.Mutex
+	messages map[uuid.UUID]models.Message
+}
+
+func (r *memMessageRepo) GetMessage(ctx context.Context, messageID uuid.UUID) (*models.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	msg, ok := r.messages[messageID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &msg, nil
+}
+
+func (r *memMessageRepo) UpdateMessage(ctx context.Context, message *models.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.messages[message.ID] = *message
+	return nil
+}
+
+type memMessageEventRepo struct {
+	mu     sync.Mutex
+	events []models.MessageEvent
+}
+
+func (r *memMessageEventRepo) CreateEvents(ctx context.Context, events []models.MessageEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, events...)
+	return nil
+}
+
+// gatedAnswer sends START and then waits: a send on finish completes the
+// answer, a cancellation fails it with the cause, as the pipeline does.
+type gatedAnswer struct {
+	messages *memMessageRepo
+	started  chan struct{}
+	finish   chan struct{}
+	// cause receives the cancellation cause of the job.
+	cause chan error
+}
+
+func (a *gatedAnswer) Answer(ctx context.Context, req AnswerRequest, events EventSender) error {
+	events.SendEvent(constant.EventStart, `{"streaming": true}`)
+	close(a.started)
+
+	msg := *req.Message
+	select {
+	case <-a.finish:
+		events.SendEvent(constant.EventMarkdownAnswer, `{"chunk": "Paris."}`)
+		msg.StreamStatus = helpers.StringPtr(constant.StreamStatusDone)
+		msg.ResponseText = helpers.StringPtr("Paris.")
+		a.messages.UpdateMessage(ctx, &msg)
+		events.SendEvent(constant.EventEnd, `{"streaming": false, "stream_status": "DONE"}`)
+		return nil
+	case <-ctx.Done():
+		cause := context.Cause(ctx)
+		a.cause <- cause
+		meta, _ := json.Marshal(map[string]any{"cancel_reason": cause.Error()})
+		msg.StreamStatus = helpers.StringPtr(constant.StreamStatusFailed)
+		msg.MetaData = datatypes.JSON(meta)
+		a.messages.UpdateMessage(context.WithoutCancel(ctx), &msg)
+		events.SendEvent(constant.EventEnd, `{"streaming": false, "stream_status": "FAILED"}`)
+		return cause
+	}
+}
+
+type sentEvent struct {
+	id    int64
+	event string
+}
+
+type eventLog struct {
+	mu     sync.Mutex
+	events []sentEvent
+	// sent, if set, receives every event as it is written.
+	sent chan sentEvent
+}
+
+func (l *eventLog) SendEventWithID(id int64, event string, data string) error {
+	l.mu.Lock()
+	l.events = append(l.events, sentEvent{id: id, event: event})
+	l.mu.Unlock()
+	if l.sent != nil {
+		l.sent <- sentEvent{id: id, event: event}
+	}
+	return nil
+}
+
+func (l *eventLog) ids() []int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var ids []int64
+	for _, e := range l.events {
+		ids = append(ids, e.id)
+	}
+	return ids
+}
+
+type generationFixture struct {
+	svc      *generationServiceImpl
+	answer   *gatedAnswer
+	messages *memMessageRepo
+	msg      *models.Message
+}
+
+// newGeneration sets up a GenerationService on an in-memory Redis with one
+// message in progress, and shortens the stream timings.
+func newGeneration(t *testing.T) *generationFixture {
+	t.Helper()
+
+	savedBlock, savedGrace := streamReadBlock, abandonGrace
+	streamReadBlock, abandonGrace = 20*time.Millisecond, 100*time.Millisecond
+	t.Cleanup(func() { streamReadBlock, abandonGrace = savedBlock, savedGrace })
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	msg := &models.Message{
+		ID:           uuid.New(),
+		ThreadID:     uuid.New(),
+		QueryText:    helpers.StringPtr("What is the capital of France?"),
+		StreamStatus: helpers.StringPtr(constant.StreamStatusInProgress),
+	}
+	messages := &memMessageRepo{messages: map[uuid.UUID]models.Message{msg.ID: *msg}}
+	answer := &gatedAnswer{
+		messages: messages,
+		started:  make(chan struct{}),
+		finish:   make(chan struct{}),
+		cause:    make(chan error, 1),
+	}
+	svc := NewGenerationService(answer, repositories.NewEventStreamRepository(rdb), messages, &memMessageEventRepo{})
+
+	return &generationFixture{svc: svc.(*generationServiceImpl), answer: answer, messages: messages, msg: msg}
+}
+
+// wait blocks until the job of the fixture's message has ended.
+func (f *generationFixture) wait(t *testing.T) {
+	t.Helper()
+
+	f.svc.mu.Lock()
+	job, ok := f.svc.jobs[f.msg.ID]
+	f.svc.mu.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case <-job.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the job did not end")
+	}
+}
+
+func TestStreamReplay(t *testing.T) {
+	f := newGeneration(t)
+	f.svc.Start(AnswerRequest{Message: f.msg})
+	<-f.answer.started
+	close(f.answer.finish)
+	f.wait(t)
+
+	tests := []struct {
+		name        string
+		lastEventID int64
+		want        []int64
+	}{
+		{name: "from the start", lastEventID: 0, want: []int64{1, 2, 3}},
+		{name: "after Last-Event-ID", lastEventID: 1, want: []int64{2, 3}},
+		{name: "after END", lastEventID: 3, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &eventLog{}
+			if err := f.svc.Stream(context.Background(), f.msg.ID, tt.lastEventID, w); err != nil {
+				t.Fatalf("Stream: %v", err)
+			}
+			if got := w.ids(); !slices.Equal(got, tt.want) {
+				t.Errorf("ids = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if err := f.svc.Stream(context.Background(), uuid.New(), 0, &eventLog{}); !errors.Is(err, ErrStreamNotFound) {
+		t.Errorf("unknown message: err = %v, want ErrStreamNotFound", err)
+	}
+}
+
+func TestStreamFollowsLiveJob(t *testing.T) {
+	f := newGeneration(t)
+	f.svc.Start(AnswerRequest{Message: f.msg})
+	<-f.answer.started
+
+	w := &eventLog{sent: make(chan sentEvent, 3)}
+	done := make(chan error, 1)
+	go func() { done <- f.svc.Stream(context.Background(), f.msg.ID, 0, w) }()
+
+	if e := <-w.sent; e.event != constant.EventStart {
+		t.Fatalf("first event = %s, want START", e.event)
+	}
+	close(f.answer.finish)
+
+	if err := <-done; err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if got := w.ids(); !slices.Equal(got, []int64{1, 2, 3}) {
+		t.Errorf("ids = %v, want [1 2 3]", got)
+	}
+}
+
+func TestStreamAbandon(t *testing.T) {
+	t.Run("cancelled after the grace period", func(t *testing.T) {
+		f := newGeneration(t)
+		f.svc.Start(AnswerRequest{Message: f.msg})
+		<-f.answer.started
+
+		f.disconnectAfterStart(t)
+
+		select {
+		case cause := <-f.answer.cause:
+			if !errors.Is(cause, errAbandoned) {
+				t.Errorf("cause = %v, want errAbandoned", cause)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("the abandoned job was not cancelled")
+		}
+		f.wait(t)
+	})
+
+	t.Run("kept by a reconnect", func(t *testing.T) {
+		f := newGeneration(t)
+		f.svc.Start(AnswerRequest{Message: f.msg})
+		<-f.answer.started
+
+		f.disconnectAfterStart(t)
+
+		// Reconnect within the grace period and stay past it.
+		w := &eventLog{sent: make(chan sentEvent, 3)}
+		done := make(chan error, 1)
+		go func() { done <- f.svc.Stream(context.Background(), f.msg.ID, 1, w) }()
+
+		select {
+		case cause := <-f.answer.cause:
+			t.Fatalf("the job was cancelled with %v while a client listened", cause)
+		case <-time.After(3 * abandonGrace):
+		}
+
+		close(f.answer.finish)
+		if err := <-done; err != nil {
+			t.Fatalf("Stream: %v", err)
+		}
+		if got := w.ids(); !slices.Equal(got, []int64{2, 3}) {
+			t.Errorf("ids after reconnect = %v, want [2 3]", got)
+		}
+	})
+}
+
+// disconnectAfterStart streams until START arrives and then leaves.
+func (f *generationFixture) disconnectAfterStart(t *testing.T) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &eventLog{sent: make(chan sentEvent, 3)}
+	done := make(chan error, 1)
+	go func() { done <- f.svc.Stream(ctx, f.msg.ID, 0, w) }()
+
+	<-w.sent
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Stream after disconnect: %v", err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	t.Run("during generation", func(t *testing.T) {
+		f := newGeneration(t)
+		f.svc.Start(AnswerRequest{Message: f.msg})
+		<-f.answer.started
+
+		w := &eventLog{}
+		done := make(chan error, 1)
+		go func() { done <- f.svc.Stream(context.Background(), f.msg.ID, 0, w) }()
+
+		msg, err := f.svc.Cancel(context.Background(), f.msg.ID, "")
+		if err != nil {
+			t.Fatalf("Cancel: %v", err)
+		}
+		if *msg.StreamStatus != constant.StreamStatusFailed {
+			t.Errorf("status = %s, want FAILED", *msg.StreamStatus)
+		}
+		if cause := <-f.answer.cause; cause.Error() != DefaultCancelReason {
+			t.Errorf("cause = %v, want %q", cause, DefaultCancelReason)
+		}
+
+		// The client still listening gets the END of the failed answer.
+		if err := <-done; err != nil {
+			t.Fatalf("Stream: %v", err)
+		}
+		if got := w.ids(); !slices.Equal(got, []int64{1, 2}) {
+			t.Errorf("ids = %v, want START and END", got)
+		}
+	})
+
+	t.Run("orphaned message", func(t *testing.T) {
+		f := newGeneration(t)
+
+		msg, err := f.svc.Cancel(context.Background(), f.msg.ID, "Too slow.")
+		if err != nil {
+			t.Fatalf("Cancel: %v", err)
+		}
+		var meta map[string]any
+		json.Unmarshal(msg.MetaData, &meta)
+		if *msg.StreamStatus != constant.StreamStatusFailed || meta["cancel_reason"] != "Too slow." {
+			t.Errorf("status %s meta %v, want FAILED with the reason", *msg.StreamStatus, meta)
+		}
+	})
+
+	t.Run("finished message", func(t *testing.T) {
+		f := newGeneration(t)
+		f.svc.Start(AnswerRequest{Message: f.msg})
+		<-f.answer.started
+		close(f.answer.finish)
+		f.wait(t)
+
+		if _, err := f.svc.Cancel(context.Background(), f.msg.ID, ""); !errors.Is(err, ErrMessageNotInProgress) {
+			t.Errorf("err = %v, want ErrMessageNotInProgress", err)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"agios/internal/config"
+	"agios/internal/repositories"
+	"agios/internal/utils/llm"
+)
+
+// SemanticCacheService reuses answers for queries that mean the same as one
+// answered recently, judged by the similarity of their embeddings.
+type SemanticCacheService interface {
+	// Lookup returns a fresh answer of tool to a query similar to query, or
+	// nil when there is none or the cache is off.
+	Lookup(ctx context.Context, tool, query string) (*repositories.CachedAnswer, error)
+	Store(ctx context.Context, answer *repositories.CachedAnswer) error
+	// Prepare sizes the answer store for the embedding model. It is called
+	// once at startup and does nothing while the cache is off.
+	Prepare(ctx context.Context) error
+}
+
+// Error definitions
+var (
+	ErrEmbeddingFailed = fmt.Errorf("EMBEDDING_FAILED")
+)
+
+type semanticCacheServiceImpl struct {
+	repo repositories.AnswerCacheRepository
+	cfg  *config.Config
+}
+
+func NewSemanticCacheService(repo repositories.AnswerCacheRepository, cfg *config.Config) SemanticCacheService {
+	return &semanticCacheServiceImpl{repo: repo, cfg: cfg}
+}
+
+func (s *semanticCacheServiceImpl) Lookup(ctx context.Context, tool, query string) (*repositories.CachedAnswer, error) {
+	if !s.cfg.SemanticCache {
+		return nil, nil
+	}
+
+	vector, err := embed(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	since := time.Now().Add(-s.cfg.SemanticCacheMaxAge)
+	return s.repo.Nearest(ctx, vector, tool, float32(s.cfg.SemanticCacheThreshold), since)
+}
+
+func (s *semanticCacheServiceImpl) Store(ctx context.Context, answer *repositories.CachedAnswer) error {
+	if !s.cfg.SemanticCache {
+		return nil
+	}
+
+	vector, err := embed(ctx, answer.Query)
+	if err != nil {
+		return err
+	}
+	return s.repo.Store(ctx, vector, answer)
+}
+
+func (s *semanticCacheServiceImpl) Prepare(ctx context.Context) error {
+	if !s.cfg.SemanticCache {
+		return nil
+	}
+
+	vector, err := embed(ctx, "dimension probe")
+	if err != nil {
+		return err
+	}
+	return s.repo.Prepare(ctx, len(vector))
+}
+
+func embed(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := llm.CreateEmbedding(ctx, []string{text})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrEmbeddingFailed, err)
+	}
+	if len(vectors[0]) == 0 {
+		return nil, fmt.Errorf("%w: empty embedding", ErrEmbeddingFailed)
+	}
+	return vectors[0], nil
+}
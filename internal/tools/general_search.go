@@ -0,0 +1,145 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"agios/internal/prompts"
+	"agios/internal/utils/constant"
+	extract "agios/internal/utils/extract"
+	"agios/internal/utils/jsonschema"
+	"agios/internal/utils/llm"
+	"agios/internal/utils/reader"
+	"agios/internal/utils/search"
+)
+
+// GeneralSearch answers from web search results. It is the default tool.
+type GeneralSearch struct{}
+
+func (t *GeneralSearch) Name() string { return constant.ToolGeneralSearch }
+
+func (t *GeneralSearch) Description() string {
+	return "Use this tool as a default if the query does not clearly fit any of the other specialized tools, or if it's a general knowledge question."
+}
+
+func (t *GeneralSearch) Parameters() *jsonschema.Schema {
+	return &jsonschema.Schema{Type: "object"}
+}
+
+func (t *GeneralSearch) Examples() []extract.ToolExample {
+	return []extract.ToolExample{
+		{Query: "Tell me about Large Language Models."},
+	}
+}
+
+func (t *GeneralSearch) WidgetType() string { return constant.WidgetSynthResults }
+
+func (t *GeneralSearch) Reusable() bool { return true }
+
+func (t *GeneralSearch) Execute(ctx context.Context, inv *Invocation) (*Result, error) {
+	inv.Emitter.Plan(constant.COTExtractingSearchTerm)
+
+	queries := []string{inv.Query}
+	terms, err := extract.ExtractSearchTerms(ctx, inv.Query)
+	if err != nil {
+		log.Printf("search term extraction failed, searching the raw query: %v", err)
+	} else if planned := terms.Queries(); len(planned) > 0 {
+		queries = planned
+	}
+
+	inv.Emitter.PlanDetails(constant.COTSearchingWeb, map[string]any{"queries": queries})
+
+	found, err := search.SearchAll(ctx, queries)
+	if err != nil {
+		return nil, fmt.Errorf("web search failed: %w", err)
+	}
+
+	if err := inv.Emitter.Send(constant.EventWebResults, map[string]any{
+		"results":   found.Results,
+		"streaming": true,
+	}); err != nil {
+		return nil, err
+	}
+
+	var passages []reader.Passage
+	if reader.Enabled() && len(found.Results) > 0 {
+		inv.Emitter.Plan(constant.COTReadingSources)
+		urls := make([]string, len(found.Results))
+		for i, r := range found.Results {
+			urls[i] = r.URL
+		}
+		passages = reader.Read(ctx, strings.Join(append([]string{inv.Query}, queries...), " "), urls)
+	}
+
+	searchResult := formatSearchResults(found, passages)
+
+	inv.Emitter.Plan(constant.COTSynthesizingResults)
+
+	prompt, err := prompts.SearchPrompt.Format(prompts.WithTuning(map[string]any{
+		"query":               inv.Query,
+		"search_result":       searchResult,
+		"file_context":        inv.FileContext,
+		"previous_chats_data": inv.PreviousChats,
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := llm.GenerateStreamResponse(llm.WithStage(ctx, constant.StageSynthesis), prompt, inv.FilePaths)
+	if err != nil {
+		return nil, err
+	}
+	text, err := inv.Emitter.StreamMarkdown(stream)
+	result := &Result{
+		Tool:         t.Name(),
+		EventType:    constant.EventMarkdownAnswer,
+		ResponseText: text,
+	}
+	if err != nil {
+		return result, err
+	}
+
+	// Takeaways are a nice-to-have; the answer has already been delivered.
+	takeaways, err := extract.ExtractTakeaways(ctx, searchResult)
+	if err != nil {
+		log.Printf("takeaway extraction failed: %v", err)
+		return result, nil
+	}
+
+	widget, err := inv.Emitter.Widget(t.WidgetType(), map[string]any{
+		"version":              "1.0",
+		"key_takeaways":        takeaways.KeyTakeaways,
+		"related_search_terms": takeaways.RelatedSearchTerms,
+		"short_summary":        takeaways.ShortSummary,
+		"metrics":              takeaways.Metrics,
+	})
+	result.Widget = widget
+
+	return result, err
+}
+
+// formatSearchResults numbers the results from 1 so the model can cite them
+// as [n], matching their order in the WEB_RESULTS event. The passages read
+// from a result's page follow its snippet.
+func formatSearchResults(found *search.Response, passages []reader.Passage) string {
+	if len(found.Results) == 0 {
+		return "No results found."
+	}
+
+	excerpts := map[int][]string{}
+	for _, p := range passages {
+		excerpts[p.Page] = append(excerpts[p.Page], p.Text)
+	}
+
+	var b strings.Builder
+	for i, r := range found.Results {
+		fmt.Fprintf(&b, "[%d] %s\nURL: %s\n%s\n", i+1, r.Title, r.URL, r.Content)
+		if len(excerpts[i]) > 0 {
+			fmt.Fprintf(&b, "Excerpts from the page:\n%s\n", strings.Join(excerpts[i], "\n...\n"))
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,30 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	extract "agios/internal/utils/extract"
+)
+
+// locate resolves a location parameter to coordinates. An empty or relative
+// location ("near me", "here") falls back to the client's IP address.
+func locate(ctx context.Context, location, clientIP string) (string, float64, float64, error) {
+	switch strings.ToLower(strings.TrimSpace(location)) {
+	case "", "near me", "nearby", "here", "around me":
+		loc := extract.ExtractLocationFromIP(clientIP)
+		if loc.Lat == 0 && loc.Lon == 0 {
+			return "", 0, 0, fmt.Errorf("%w: no location for client %s", ErrNotApplicable, clientIP)
+		}
+		return loc.City, loc.Lat, loc.Lon, nil
+	}
+
+	lat, lon, err := extract.GeocodeCity(ctx, location)
+	if err != nil {
+		log.Printf("geocoding %q failed: %v", location, err)
+		return "", 0, 0, fmt.Errorf("%w: cannot geocode %q", ErrNotApplicable, location)
+	}
+	return location, lat, lon, nil
+}
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"agios/internal/prompts"
+	"agios/internal/utils/constant"
+	extract "agios/internal/utils/extract"
+	"agios/internal/utils/jsonschema"
+	"agios/internal/utils/llm"
+)
+
+const (
+	nearbySearchRadius = 2000 // meters
+	nearbyMaxResults   = 10
+)
+
+// NearbyBusinesses finds places around a location with Google Places.
+type NearbyBusinesses struct{}
+
+func (t *NearbyBusinesses) Name() string { return constant.ToolNearbyBusinesses }
+
+func (t *NearbyBusinesses) Description() string {
+	return "Use this tool when the user is looking for businesses or points of interest nearby or in a specified location."
+}
+
+func (t *NearbyBusinesses) Parameters() *jsonschema.Schema {
+	return &jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"location":      {Type: "string", Description: `The area to search for businesses (e.g., "San Francisco", "near me").`},
+			"business_type": {Type: "string", Description: `The category of business (e.g., "cafe", "restaurant", "electronics store", "coffee shops").`},
+			"keyword":       {Type: "string", Description: `A specific name or search term for a business (e.g., "Starbucks", "quiet study spot").`},
+		},
+	}
+}
+
+func (t *NearbyBusinesses) Examples() []extract.ToolExample {
+	return []extract.ToolExample{
+		{Query: "Find me some coffee shops nearby.", Args: map[string]any{"business_type": "coffee shops"}},
+		{Query: "Find me a Starbucks in downtown.", Args: map[string]any{"location": "downtown", "keyword": "Starbucks"}},
+	}
+}
+
+func (t *NearbyBusinesses) WidgetType() string { return constant.WidgetNearbyPlaces }
+
+func (t *NearbyBusinesses) Execute(ctx context.Context, inv *Invocation) (*Result, error) {
+	inv.Emitter.Plan(constant.COTExtractingNearbyPlaces)
+
+	name, lat, lon, err := locate(ctx, inv.Param("location"), inv.ClientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	keyword := strings.TrimSpace(inv.Param("business_type") + " " + inv.Param("keyword"))
+	places, err := extract.GetNearbyPlaces(ctx, lat, lon, nearbySearchRadius, "", keyword, nearbyMaxResults)
+	if err != nil {
+		return nil, fmt.Errorf("nearby places lookup failed: %w", err)
+	}
+
+	widget, err := inv.Emitter.Widget(t.WidgetType(), map[string]any{
+		"location":  name,
+		"latitude":  lat,
+		"longitude": lon,
+		"keyword":   keyword,
+		"places":    places,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	businessData, _ := json.Marshal(places)
+	prompt, err := prompts.BusinessSummaryPrompt.Format(prompts.WithTuning(map[string]any{
+		"business_data": fmt.Sprintf("Searched for: %s\n%s", keyword, businessData),
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := llm.GenerateStreamResponse(llm.WithStage(ctx, constant.StageBusinessSummary), prompt, nil)
+	if err != nil {
+		return nil, err
+	}
+	text, err := inv.Emitter.StreamMarkdown(stream)
+
+	return &Result{
+		Tool:         t.Name(),
+		EventType:    constant.EventWidget,
+		ResponseText: text,
+		Widget:       widget,
+	}, err
+}
@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"sync"
+
+	extract "agios/internal/utils/extract"
+)
+
+// Registry holds the tools available to the answer pipeline. The first tool
+// registered is the default used when detection fails or a tool is not
+// applicable.
+type Registry struct {
+	mu    sync.RWMutex
+	tools []Tool
+	index map[string]Tool
+}
+
+// NewRegistry constructs a Registry with the given tools registered in order.
+func NewRegistry(tools ...Tool) *Registry {
+	r := &Registry{index: map[string]Tool{}}
+	for _, t := range tools {
+		r.Register(t)
+	}
+	return r
+}
+
+// NewDefaultRegistry returns a Registry with the built-in tools.
+func NewDefaultRegistry() *Registry {
+	return NewRegistry(
+		&GeneralSearch{},
+		&YoutubeSummary{},
+		&WeatherForecast{},
+		&NearbyBusinesses{},
+	)
+}
+
+// Register adds t, replacing any tool registered under the same name.
+func (r *Registry) Register(t Tool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.index[t.Name()]; exists {
+		for i, existing := range r.tools {
+			if existing.Name() == t.Name() {
+				r.tools[i] = t
+			}
+		}
+	} else {
+		r.tools = append(r.tools, t)
+	}
+	r.index[t.Name()] = t
+}
+
+// Get returns the tool registered under name.
+func (r *Registry) Get(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, ok := r.index[name]
+	return t, ok
+}
+
+// Default returns the fallback tool.
+func (r *Registry) Default() Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.tools) == 0 {
+		return nil
+	}
+	return r.tools[0]
+}
+
+// All returns the registered tools in registration order.
+func (r *Registry) All() []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]Tool(nil), r.tools...)
+}
+
+// Names returns the registered tool names in registration order.
+func (r *Registry) Names() []string {
+	all := r.All()
+	names := make([]string, len(all))
+	for i, t := range all {
+		names[i] = t.Name()
+	}
+	return names
+}
+
+// Catalog describes the registered tools for the tool detector.
+func (r *Registry) Catalog() extract.ToolCatalog {
+	all := r.All()
+	catalog := extract.ToolCatalog{Tools: make([]extract.ToolSpec, len(all))}
+	for i, t := range all {
+		catalog.Tools[i] = extract.ToolSpec{
+			Name:        t.Name(),
+			Description: t.Description(),
+			Parameters:  t.Parameters(),
+		}
+		if e, ok := t.(Exemplified); ok {
+			catalog.Tools[i].Examples = e.Examples()
+		}
+	}
+	if d := r.Default(); d != nil {
+		catalog.Default = d.Name()
+	}
+	return catalog
+}
@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	extract "agios/internal/utils/extract"
+	"agios/internal/utils/jsonschema"
+	"agios/internal/utils/llm"
+)
+
+// ErrNotApplicable is returned by a tool that cannot answer the query with
+// the parameters it was given. The pipeline then falls back to the default
+// tool.
+var ErrNotApplicable = errors.New("TOOL_NOT_APPLICABLE")
+
+// Emitter streams a tool's progress to the client.
+type Emitter interface {
+	Plan(cot string) error
+	// PlanDetails sends a PLAN event carrying details next to cot.
+	PlanDetails(cot string, details map[string]any) error
+	Send(event string, payload any) error
+	Widget(widgetType string, widgetData any) (map[string]any, error)
+	StreamMarkdown(stream *llm.Stream) (string, error)
+}
+
+// Invocation is everything a tool needs to answer a single query.
+type Invocation struct {
+	Query         string
+	Params        map[string]any
+	ClientIP      string
+	FilePaths     []string
+	FileContext   string
+	PreviousChats string
+	Emitter       Emitter
+}
+
+// Param returns the named parameter as a trimmed string, or "" if the
+// detector did not supply it.
+func (inv *Invocation) Param(name string) string {
+	v, ok := inv.Params[name]
+	if !ok || v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return strings.TrimSpace(s)
+	}
+	return strings.TrimSpace(fmt.Sprint(v))
+}
+
+// Result is what a tool hands back once it has streamed its events.
+type Result struct {
+	Tool         string
+	EventType    string
+	ResponseText string
+	Widget       map[string]any
+}
+
+// Tool is a capability the answer pipeline can dispatch a query to.
+type Tool interface {
+	// Name is the identifier the detector returns, e.g. "weather_forecast".
+	Name() string
+	// Description tells the detector when to pick this tool.
+	Description() string
+	// Parameters is the JSON schema of the parameters the detector extracts.
+	Parameters() *jsonschema.Schema
+	// WidgetType is the widget the tool emits, or "" if it emits none.
+	WidgetType() string
+	Execute(ctx context.Context, inv *Invocation) (*Result, error)
+}
+
+// Reusable is implemented by tools whose answers stay true for a while, so
+// the answer to a similar query may be served again. Tools reporting live
+// data, such as the weather, must not implement it.
+type Reusable interface {
+	Reusable() bool
+}
+
+// Exemplified is implemented by tools that show the detector example
+// queries they answer, with the arguments to call them with.
+type Exemplified interface {
+	Examples() []extract.ToolExample
+}
@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"agios/internal/prompts"
+	"agios/internal/utils/constant"
+	extract "agios/internal/utils/extract"
+	"agios/internal/utils/jsonschema"
+	"agios/internal/utils/llm"
+)
+
+// WeatherForecast answers weather questions with an Open-Meteo forecast.
+type WeatherForecast struct{}
+
+func (t *WeatherForecast) Name() string { return constant.ToolWeatherForecast }
+
+func (t *WeatherForecast) Description() string {
+	return "Use this tool when the user asks about the weather. If the user wants the weather where they are rather than for a named place, leave `location` out."
+}
+
+func (t *WeatherForecast) Parameters() *jsonschema.Schema {
+	return &jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"location": {Type: "string", Description: `The location for which the weather forecast is requested (e.g., "London", "Paris, FR").`},
+		},
+	}
+}
+
+func (t *WeatherForecast) Examples() []extract.ToolExample {
+	return []extract.ToolExample{
+		{Query: "What's the weather like in Berlin?", Args: map[string]any{"location": "Berlin"}},
+		{Query: "What's the weather around me?"},
+		{Query: "Weather forecast for today"},
+	}
+}
+
+func (t *WeatherForecast) WidgetType() string { return constant.WidgetWeather }
+
+func (t *WeatherForecast) Execute(ctx context.Context, inv *Invocation) (*Result, error) {
+	inv.Emitter.Plan(constant.COTExtractingWeather)
+
+	name, lat, lon, err := locate(ctx, inv.Param("location"), inv.ClientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	forecast, err := extract.GetWeatherForecast(ctx, "", &lat, &lon)
+	if err != nil {
+		return nil, fmt.Errorf("weather forecast failed: %w", err)
+	}
+
+	widget, err := inv.Emitter.Widget(t.WidgetType(), map[string]any{
+		"location":  name,
+		"latitude":  lat,
+		"longitude": lon,
+		"forecast":  forecast,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	weatherData, _ := json.Marshal(forecast)
+	prompt, err := prompts.WeatherSummaryPrompt.Format(prompts.WithTuning(map[string]any{
+		"weather_data": fmt.Sprintf("Location: %s\n%s", name, weatherData),
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := llm.GenerateStreamResponse(llm.WithStage(ctx, constant.StageWeatherSummary), prompt, nil)
+	if err != nil {
+		return nil, err
+	}
+	text, err := inv.Emitter.StreamMarkdown(stream)
+
+	return &Result{
+		Tool:         t.Name(),
+		EventType:    constant.EventWidget,
+		ResponseText: text,
+		Widget:       widget,
+	}, err
+}
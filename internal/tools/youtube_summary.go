@@ -0,0 +1,75 @@
+package tools
+
+import (
+	"context"
+
+	"agios/internal/prompts"
+	"agios/internal/utils/constant"
+	extract "agios/internal/utils/extract"
+	"agios/internal/utils/jsonschema"
+	"agios/internal/utils/llm"
+)
+
+// YoutubeSummary summarizes a YouTube video by handing its URL to the model.
+type YoutubeSummary struct{}
+
+func (t *YoutubeSummary) Name() string { return constant.ToolYoutubeSummary }
+
+func (t *YoutubeSummary) Description() string {
+	return "Use this tool when the user asks to summarize a YouTube video."
+}
+
+func (t *YoutubeSummary) Parameters() *jsonschema.Schema {
+	return &jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"video_url": {Type: "string", Description: "The full URL of the YouTube video to be summarized."},
+		},
+		Required: []string{"video_url"},
+	}
+}
+
+func (t *YoutubeSummary) Examples() []extract.ToolExample {
+	return []extract.ToolExample{
+		{Query: "Can you summarize this YouTube video for me? https://www.youtube.com/watch?v=dQw4w9WgXcQ", Args: map[string]any{"video_url": "https://www.youtube.com/watch?v=dQw4w9WgXcQ"}},
+	}
+}
+
+func (t *YoutubeSummary) WidgetType() string { return constant.WidgetYTSummary }
+
+func (t *YoutubeSummary) Execute(ctx context.Context, inv *Invocation) (*Result, error) {
+	videoURL := inv.Param("video_url")
+	if videoURL == "" {
+		return nil, ErrNotApplicable
+	}
+
+	inv.Emitter.Plan(constant.COTExtractingYTTranscript)
+
+	widget, err := inv.Emitter.Widget(t.WidgetType(), map[string]any{
+		"version":     "1.0",
+		"youtube_url": videoURL,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	prompt, err := prompts.YoutubeSummaryPrompt.Format(prompts.WithTuning(map[string]any{
+		"transcript": "The video is attached to this prompt; summarize its spoken content.",
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := llm.GenerateVideoStreamResponse(llm.WithStage(ctx, constant.StageYoutubeSummary), prompt, videoURL)
+	if err != nil {
+		return nil, err
+	}
+	text, err := inv.Emitter.StreamMarkdown(stream)
+
+	return &Result{
+		Tool:         t.Name(),
+		EventType:    constant.EventWidget,
+		ResponseText: text,
+		Widget:       widget,
+	}, err
+}
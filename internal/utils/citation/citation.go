@@ -0,0 +1,231 @@
+// Package citation checks the [n] markers of a streamed answer against the
+// numbered sources the answer was written from.
+package citation
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Modes for out-of-range markers.
+const (
+	// ModeDrop removes them from the answer.
+	ModeDrop = "drop"
+	// ModeFlag leaves them in the answer; they are only reported.
+	ModeFlag = "flag"
+)
+
+// maxPending is the longest partial marker held back between chunks.
+const maxPending = 24
+
+// marker matches [n] and the [n, m] groups models write despite being told
+// not to, at the start of the text.
+var marker = regexp.MustCompile(`^\[(\d{1,3}(?:\s*,\s*\d{1,3})*)\]`)
+
+// partial matches the start of a marker running to the end of a chunk.
+var partial = regexp.MustCompile(`^\[[\d,\s]*$`)
+
+// Source is a numbered source; Index starts at 1.
+type Source struct {
+	Index int    `json:"index"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// Citation is a source the answer cites.
+type Citation struct {
+	Source
+	// Count is how often the answer cites the source.
+	Count int `json:"count"`
+}
+
+// SourcesFromWebResults numbers the results of a WEB_RESULTS payload from 1,
+// the way they are numbered in the prompt.
+func SourcesFromWebResults(payload json.RawMessage) []Source {
+	var parsed struct {
+		Results []struct {
+			Title string `json:"title"`
+			URL   string `json:"url"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(payload, &parsed); err != nil {
+		return nil
+	}
+	sources := make([]Source, len(parsed.Results))
+	for i, r := range parsed.Results {
+		sources[i] = Source{Index: i + 1, Title: r.Title, URL: r.URL}
+	}
+	return sources
+}
+
+// Filter rewrites the markers of an answer as it streams. Markers split
+// across chunks are held back until they are complete. Brackets inside
+// inline code and fenced blocks, after an identifier as in arr[0], and
+// followed by "(" or ":" as in links and link definitions are not markers.
+type Filter struct {
+	sources []Source
+	mode    string
+	pending string
+	counts  map[int]int
+	invalid map[int]int
+
+	// prev is the last rune passed on; '\n' at the start.
+	prev rune
+	// cited reports whether prev closed a marker, so [1][2] is two
+	// markers while m[1][2] is none.
+	cited bool
+	// fenced is set inside a ``` block, inline to the length of the
+	// backtick run that opened inline code.
+	fenced bool
+	inline int
+}
+
+// NewFilter returns a Filter for an answer written from sources.
+func NewFilter(sources []Source, mode string) *Filter {
+	if mode != ModeFlag {
+		mode = ModeDrop
+	}
+	return &Filter{sources: sources, mode: mode, counts: map[int]int{}, invalid: map[int]int{}, prev: '\n'}
+}
+
+// Write takes the next chunk of the answer and returns the text that can
+// be passed on.
+func (f *Filter) Write(chunk string) string {
+	return f.scan(f.pending+chunk, false)
+}
+
+// Flush returns the text still held back once the answer has ended.
+func (f *Filter) Flush() string {
+	return f.scan(f.pending, true)
+}
+
+// scan rewrites the markers of text. Unless final, a tail that the next
+// chunk may still change, a backtick run or an unfinished or unfollowed
+// marker, is kept in pending.
+func (f *Filter) scan(text string, final bool) string {
+	f.pending = ""
+	var out strings.Builder
+	for i := 0; i < len(text); {
+		rest := text[i:]
+
+		if rest[0] == '`' {
+			n := len(rest) - len(strings.TrimLeft(rest, "`"))
+			if n == len(rest) && !final {
+				f.pending = rest
+				break
+			}
+			f.backticks(n)
+			out.WriteString(rest[:n])
+			f.prev, f.cited = '`', false
+			i += n
+			continue
+		}
+
+		if rest[0] == '[' && !f.fenced && f.inline == 0 && f.markerMayFollow() {
+			if loc := marker.FindStringIndex(rest); loc != nil {
+				if loc[1] == len(rest) && !final {
+					f.pending = rest
+					break
+				}
+				if next := rest[loc[1]:]; next == "" || (next[0] != '(' && next[0] != ':') {
+					out.WriteString(f.rewrite(rest[:loc[1]]))
+					f.prev, f.cited = ']', true
+					i += loc[1]
+					continue
+				}
+			} else if !final && len(rest) <= maxPending && partial.MatchString(rest) {
+				f.pending = rest
+				break
+			}
+		}
+
+		r, size := utf8.DecodeRuneInString(rest)
+		out.WriteString(rest[:size])
+		f.prev, f.cited = r, false
+		i += size
+	}
+	return out.String()
+}
+
+// backticks updates the code state for a run of n backticks.
+func (f *Filter) backticks(n int) {
+	switch {
+	case f.fenced:
+		if n >= 3 {
+			f.fenced = false
+		}
+	case f.inline > 0:
+		if n == f.inline {
+			f.inline = 0
+		}
+	case n >= 3 && f.prev == '\n':
+		f.fenced = true
+	default:
+		f.inline = n
+	}
+}
+
+// markerMayFollow reports whether a "[" after prev can open a marker: at
+// the start of a line or after punctuation or space, not after an
+// identifier or closing bracket as in x[10] or f(x)[0].
+func (f *Filter) markerMayFollow() bool {
+	switch {
+	case f.prev == ']':
+		return f.cited
+	case f.prev == '_' || f.prev == ')':
+		return false
+	default:
+		return !unicode.IsLetter(f.prev) && !unicode.IsDigit(f.prev)
+	}
+}
+
+// rewrite keeps the in-range indices of marker m, and the others in flag
+// mode, as separate [n] markers.
+func (f *Filter) rewrite(m string) string {
+	var kept []string
+	for _, field := range strings.Split(m[1:len(m)-1], ",") {
+		n, _ := strconv.Atoi(strings.TrimSpace(field))
+		if n >= 1 && n <= len(f.sources) {
+			f.counts[n]++
+			kept = append(kept, "["+strconv.Itoa(n)+"]")
+			continue
+		}
+		f.invalid[n]++
+		if f.mode == ModeFlag {
+			kept = append(kept, "["+strconv.Itoa(n)+"]")
+		}
+	}
+	return strings.Join(kept, "")
+}
+
+// Strip removes the citation markers from text, as for answers shown again
+// without the sources they cite. Code and links are kept as they are.
+func Strip(text string) string {
+	f := NewFilter(nil, ModeDrop)
+	return f.Write(text) + f.Flush()
+}
+
+// Citations returns the sources cited so far, in index order.
+func (f *Filter) Citations() []Citation {
+	cited := make([]Citation, 0, len(f.counts))
+	for n, count := range f.counts {
+		cited = append(cited, Citation{Source: f.sources[n-1], Count: count})
+	}
+	sort.Slice(cited, func(i, j int) bool { return cited[i].Index < cited[j].Index })
+	return cited
+}
+
+// Invalid returns the out-of-range indices cited so far, in order.
+func (f *Filter) Invalid() []int {
+	invalid := make([]int, 0, len(f.invalid))
+	for n := range f.invalid {
+		invalid = append(invalid, n)
+	}
+	sort.Ints(invalid)
+	return invalid
+}
@@ -0,0 +1,102 @@
+package citation
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testSources = []Source{
+	{Index: 1, Title: "One", URL: "https://one.example"},
+	{Index: 2, Title: "Two", URL: "https://two.example"},
+}
+
+func TestFilterMarkers(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		in      string
+		want    string
+		invalid []int
+	}{
+		{"in range", ModeDrop, "Paris is big [1].", "Paris is big [1].", nil},
+		{"group", ModeDrop, "Both agree [1, 2].", "Both agree [1][2].", nil},
+		{"adjacent", ModeDrop, "Both agree [1][2].", "Both agree [1][2].", nil},
+		{"start of line", ModeDrop, "[2] says so.", "[2] says so.", nil},
+		{"out of range dropped", ModeDrop, "Claim [7].", "Claim .", []int{7}},
+		{"out of range flagged", ModeFlag, "Claim [7].", "Claim [7].", []int{7}},
+		{"zero dropped", ModeDrop, "Claim [0].", "Claim .", []int{0}},
+		{"group partly out of range", ModeDrop, "Claim [1, 9].", "Claim [1].", []int{9}},
+		{"index after identifier", ModeDrop, "Use arr[0] and x[10].", "Use arr[0] and x[10].", nil},
+		{"chained index", ModeDrop, "Read matrix[1, 2] and m[1][2].", "Read matrix[1, 2] and m[1][2].", nil},
+		{"index after call", ModeDrop, "Take f(x)[0].", "Take f(x)[0].", nil},
+		{"link", ModeDrop, "See [1](https://x.example) and [9](https://y.example).", "See [1](https://x.example) and [9](https://y.example).", nil},
+		{"link definition", ModeDrop, "[9]: https://x.example", "[9]: https://x.example", nil},
+		{"inline code", ModeDrop, "Write `xs [0]` here [1].", "Write `xs [0]` here [1].", nil},
+		{"double backtick code", ModeDrop, "Write ``a ` [5]`` here.", "Write ``a ` [5]`` here.", nil},
+		{"fenced block", ModeDrop, "Code:\n```\nprint([0])\nitems [5]\n```\nDone [2].", "Code:\n```\nprint([0])\nitems [5]\n```\nDone [2].", nil},
+		{"not a marker", ModeDrop, "A [note] and [ ] stay.", "A [note] and [ ] stay.", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFilter(testSources, tt.mode)
+			got := f.Write(tt.in) + f.Flush()
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if invalid := f.Invalid(); len(invalid) != len(tt.invalid) || (len(invalid) > 0 && !reflect.DeepEqual(invalid, tt.invalid)) {
+				t.Errorf("invalid = %v, want %v", invalid, tt.invalid)
+			}
+		})
+	}
+}
+
+func TestFilterSplitChunks(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{"split marker", []string{"Big [", "1", "] city."}, "Big [1] city."},
+		{"split group", []string{"Both [1,", " 2] agree."}, "Both [1][2] agree."},
+		{"split dropped", []string{"Claim [", "9]."}, "Claim ."},
+		{"marker then link", []string{"See [9]", "(https://x.example)."}, "See [9](https://x.example)."},
+		{"marker at end", []string{"Done [2]"}, "Done [2]"},
+		{"split fence", []string{"Code:\n`", "``\nx[5] = [9]\n``", "`\nOk [1]."}, "Code:\n```\nx[5] = [9]\n```\nOk [1]."},
+		{"split inline code", []string{"Run `", "f [9]", "` now."}, "Run `f [9]` now."},
+		{"unfinished bracket", []string{"Odd [", "x] text"}, "Odd [x] text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFilter(testSources, ModeDrop)
+			var got string
+			for _, chunk := range tt.chunks {
+				got += f.Write(chunk)
+			}
+			got += f.Flush()
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterCitations(t *testing.T) {
+	f := NewFilter(testSources, ModeDrop)
+	f.Write("A [2]. B [1, 2]. C `[1]`.")
+	f.Flush()
+
+	want := []Citation{{Source: testSources[0], Count: 1}, {Source: testSources[1], Count: 2}}
+	if got := f.Citations(); !reflect.DeepEqual(got, want) {
+		t.Errorf("citations = %+v, want %+v", got, want)
+	}
+}
+
+func TestStrip(t *testing.T) {
+	in := "Paris [1][2] has arr[0] and `[3]` and [4](https://x.example)."
+	want := "Paris  has arr[0] and `[3]` and [4](https://x.example)."
+	if got := Strip(in); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
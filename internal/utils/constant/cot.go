@@ -3,12 +3,15 @@ package constant
 const (
 	COTStarted                = "Starting up the reasoning process."
 	COTEnded                  = "Finished with the reasoning."
+	COTRewritingQuery         = "Reading the question in the context of the conversation."
 	COTMakingToolDecision     = "Deciding which tool fits best."
 	COTExtractingWeather      = "Extracting weather information."
 	COTExtractingNearbyPlaces = "Finding nearby places of interest."
 	COTExtractingYTTranscript = "Extracting transcript from the video."
 	COTExtractingSearchTerm   = "Identifying the search term."
 	COTSearchingWeb           = "Searching the web for relevant data."
+	COTReadingSources         = "Reading the top sources."
 	COTLookingCryptoUpdate    = "Fetching the latest crypto updates."
 	COTSynthesizingResults    = "Synthesizing everything into a final result."
+	COTReusingAnswer          = "Reusing the answer to a similar question."
 )
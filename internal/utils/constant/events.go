@@ -7,4 +7,5 @@ const (
 	EventWebResults     = "WEB_RESULTS"
 	EventMarkdownAnswer = "MARKDOWN_ANSWER"
 	EventWidget         = "WIDGET"
+	EventRelatedQueries = "RELATED_QUERIES"
 )
@@ -0,0 +1,23 @@
+package constant
+
+// Pipeline stages that call an LLM, as recorded in usage accounting.
+const (
+	StageQueryRewrite    = "query_rewrite"
+	StageToolDetection   = "tool_detection"
+	StageSearchTerms     = "search_terms"
+	StageSynthesis       = "synthesis"
+	StageTakeaways       = "takeaways"
+	StageWeatherSummary  = "weather_summary"
+	StageBusinessSummary = "business_summary"
+	StageYoutubeSummary  = "youtube_summary"
+	StageRelatedQueries  = "related_queries"
+	StageHistorySummary  = "history_summary"
+)
+
+// Tool calls cached like LLM calls, each under its own stage.
+const (
+	StageWebSearch = "web_search"
+	StagePlaces    = "places"
+	StageWeather   = "weather"
+	StageGeocode   = "geocode"
+)
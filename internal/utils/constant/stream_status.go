@@ -0,0 +1,7 @@
+package constant
+
+const (
+	StreamStatusInProgress = "IN_PROGRESS"
+	StreamStatusDone       = "DONE"
+	StreamStatusFailed     = "FAILED"
+)
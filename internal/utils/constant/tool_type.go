@@ -0,0 +1,8 @@
+package constant
+
+const (
+	ToolYoutubeSummary   = "youtube_summary"
+	ToolWeatherForecast  = "weather_forecast"
+	ToolNearbyBusinesses = "nearby_businesses"
+	ToolGeneralSearch    = "general_search"
+)
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"agios/internal/prompts"
+	"agios/internal/utils/constant"
+	"agios/internal/utils/llm"
+)
+
+// ConversationSummary is the running summary of the earlier turns of a
+// thread.
+type ConversationSummary struct {
+	Summary string `json:"summary"`
+}
+
+// SummarizeConversation folds turns, rendered earlier turns of a
+// conversation, into summary and returns the updated summary of at most
+// about maxWords words. An empty summary starts a new one.
+func SummarizeConversation(ctx context.Context, summary, turns string, maxWords int) (string, error) {
+	if strings.TrimSpace(summary) == "" {
+		summary = "None."
+	}
+	out, err := Extract[ConversationSummary](llm.WithStage(ctx, constant.StageHistorySummary), prompts.HistorySummaryPrompt, map[string]any{
+		"summary":  summary,
+		"turns":    turns,
+		"maxWords": maxWords,
+	})
+	if err != nil {
+		return "", err
+	}
+	updated := strings.TrimSpace(out.Summary)
+	if updated == "" {
+		return "", errors.New("empty conversation summary")
+	}
+	return updated, nil
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"agios/internal/utils/llm"
+	"agios/internal/utils/resilience"
+)
+
+// getJSON fetches apiURL from service and decodes its JSON body into out.
+// Transient failures are retried under the service's circuit breaker.
+func getJSON(ctx context.Context, service string, client *http.Client, apiURL string, out any) error {
+	return resilience.Do(ctx, service, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+		if err != nil {
+			return fmt.Errorf("creating request: %w", err)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= 400 {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			return resilience.NewStatusError(service, resp, string(body))
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("decoding response: %w", err)
+		}
+		return nil
+	})
+}
+
+// getCachedJSON is getJSON with the body cached under stage by apiURL.
+func getCachedJSON(ctx context.Context, stage, service string, client *http.Client, apiURL string, out any) error {
+	body, err := llm.Cached(ctx, stage, apiURL, func(ctx context.Context) (json.RawMessage, error) {
+		var body json.RawMessage
+		err := getJSON(ctx, service, client, apiURL, &body)
+		return body, err
+	})
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
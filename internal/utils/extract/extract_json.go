@@ -1,17 +1,40 @@
 package utils
 
+// extractJSONSegment returns the first balanced JSON object in raw. Braces
+// inside string literals, including escaped quotes, are ignored.
 func extractJSONSegment(raw string) (string, bool) {
 	start := -1
 	depth := 0
+	inString := false
+	escaped := false
 
 	for i, r := range raw {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case r == '"':
+				inString = false
+			}
+			continue
+		}
+
 		switch r {
+		case '"':
+			if start != -1 {
+				inString = true
+			}
 		case '{':
 			if depth == 0 {
 				start = i
 			}
 			depth++
 		case '}':
+			if depth == 0 {
+				continue
+			}
 			depth--
 			if depth == 0 && start != -1 {
 				return raw[start : i+1], true
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"testing"
+
+	"agios/internal/utils/jsonschema"
+)
+
+func TestExtractJSONSegment(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+		ok   bool
+	}{
+		{"bare object", `{"a": 1}`, `{"a": 1}`, true},
+		{"surrounding prose", `Sure! Here it is: {"a": 1} Hope that helps.`, `{"a": 1}`, true},
+		{"fenced", "```json\n{\"a\": [1, 2]}\n```", `{"a": [1, 2]}`, true},
+		{"nested", `{"a": {"b": {"c": 1}}, "d": 2}`, `{"a": {"b": {"c": 1}}, "d": 2}`, true},
+		{"braces in strings", `{"a": "}{", "b": "{x}"}`, `{"a": "}{", "b": "{x}"}`, true},
+		{"escaped quote", `{"a": "say \"}\" now", "b": 1}`, `{"a": "say \"}\" now", "b": 1}`, true},
+		{"escaped backslash", `{"a": "C:\\", "b": "}"}`, `{"a": "C:\\", "b": "}"}`, true},
+		{"first of two", `{"a": 1} {"b": 2}`, `{"a": 1}`, true},
+		{"stray closing brace", `} oops {"a": 1}`, `{"a": 1}`, true},
+		{"multibyte text", `Résumé → {"a": "café"}`, `{"a": "café"}`, true},
+		{"unbalanced", `{"a": {"b": 1}`, "", false},
+		{"no object", `["a", "b"]`, "", false},
+		{"empty", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := extractJSONSegment(tt.raw)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("got %q, %v; want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+type decodeTarget struct {
+	Name  string   `json:"name"`
+	Count int      `json:"count"`
+	Tags  []string `json:"tags"`
+	Note  *string  `json:"note"`
+}
+
+func TestDecodeAndValidate(t *testing.T) {
+	schema := jsonschema.For[decodeTarget]()
+
+	tests := []struct {
+		name     string
+		raw      string
+		wantStep string
+	}{
+		{"valid", "```json\n{\"name\": \"x\", \"count\": 2, \"tags\": [\"a\"]}\n```", ""},
+		{"no json", "I cannot answer that.", StepLocate},
+		{"bad json", `{"name": "x", "count": 2,}`, StepDecode},
+		{"missing field", `{"name": "x", "tags": []}`, StepValidate},
+		{"wrong type", `{"name": "x", "count": "two", "tags": []}`, StepValidate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeAndValidate[decodeTarget](tt.raw, schema)
+			if tt.wantStep == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got.Name != "x" || got.Count != 2 || len(got.Tags) != 1 || got.Note != nil {
+					t.Errorf("decoded %+v", got)
+				}
+				return
+			}
+			if err == nil || err.Step != tt.wantStep {
+				t.Errorf("err = %v, want step %s", err, tt.wantStep)
+			}
+		})
+	}
+}
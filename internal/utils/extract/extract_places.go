@@ -2,13 +2,16 @@ package utils
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"sync"
 
+	"agios/internal/utils/constant"
+	"agios/internal/utils/llm"
+
 	"github.com/joho/godotenv"
 )
 
@@ -36,20 +39,14 @@ func init() {
 	_ = godotenv.Load()
 	apiKey = os.Getenv("GOOGLE_MAP_KEY")
 	if apiKey == "" {
-		log.Fatal("GOOGLE_MAP_KEY not found in environment variables")
+		log.Println("GOOGLE_MAP_KEY not found in environment variables. Nearby places lookups will fail.")
 	}
 }
 
 func getPlaceDetails(ctx context.Context, placeID string, maxPhotos int) (map[string]any, error) {
 	url := fmt.Sprintf("https://maps.googleapis.com/maps/api/place/details/json?place_id=%s&fields=name,formatted_address,international_phone_number,website,rating,opening_hours,photo,price_level,business_status,url,user_ratings_total&key=%s", placeID, apiKey)
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
 	var result map[string]any
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	if err := getJSON(ctx, "google_places", http.DefaultClient, url, &result); err != nil {
 		return nil, err
 	}
 
@@ -69,23 +66,30 @@ func getPlaceDetails(ctx context.Context, placeID string, maxPhotos int) (map[st
 	return place, nil
 }
 
+// GetNearbyPlaces returns up to maxResults places around lat, lng with
+// their details. Results are cached under the places stage.
 func GetNearbyPlaces(ctx context.Context, lat, lng float64, radius int, placeType, keyword string, maxResults int) ([]PlaceDetails, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("GOOGLE_MAP_KEY not set")
+	}
+
+	key := []any{fmt.Sprintf("%.6f,%.6f", lat, lng), radius, placeType, keyword, maxResults}
+	return llm.Cached(ctx, constant.StagePlaces, key, func(ctx context.Context) ([]PlaceDetails, error) {
+		return nearbyPlaces(ctx, lat, lng, radius, placeType, keyword, maxResults)
+	})
+}
+
+func nearbyPlaces(ctx context.Context, lat, lng float64, radius int, placeType, keyword string, maxResults int) ([]PlaceDetails, error) {
 	baseURL := fmt.Sprintf("https://maps.googleapis.com/maps/api/place/nearbysearch/json?location=%.6f,%.6f&radius=%d&key=%s", lat, lng, radius, apiKey)
 	if placeType != "" {
-		baseURL += "&type=" + placeType
+		baseURL += "&type=" + url.QueryEscape(placeType)
 	}
 	if keyword != "" {
-		baseURL += "&keyword=" + keyword
-	}
-
-	resp, err := http.Get(baseURL)
-	if err != nil {
-		return nil, err
+		baseURL += "&keyword=" + url.QueryEscape(keyword)
 	}
-	defer resp.Body.Close()
 
 	var data map[string]any
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+	if err := getJSON(ctx, "google_places", http.DefaultClient, baseURL, &data); err != nil {
 		return nil, err
 	}
 
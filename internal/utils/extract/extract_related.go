@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"context"
+	"strings"
+
+	"agios/internal/prompts"
+	"agios/internal/utils/constant"
+	"agios/internal/utils/llm"
+)
+
+// MaxRelatedQueries caps the follow-up questions suggested for an answer.
+const MaxRelatedQueries = 6
+
+// RelatedQueries are follow-up questions to an answer.
+type RelatedQueries struct {
+	Questions []string `json:"questions"`
+}
+
+// ExtractRelatedQueries suggests follow-up questions from the query, the
+// titles of the sources and the answer. The questions are trimmed,
+// deduplicated and capped at MaxRelatedQueries.
+func ExtractRelatedQueries(ctx context.Context, query, sources, answer string) ([]string, error) {
+	out, err := Extract[RelatedQueries](llm.WithStage(ctx, constant.StageRelatedQueries), prompts.RelatedQueriesPrompt, map[string]any{
+		"query":   query,
+		"sources": sources,
+		"answer":  answer,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{strings.ToLower(strings.TrimSpace(query)): true}
+	var questions []string
+	for _, q := range out.Questions {
+		q = strings.TrimSpace(q)
+		key := strings.ToLower(q)
+		if q == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		questions = append(questions, q)
+		if len(questions) == MaxRelatedQueries {
+			break
+		}
+	}
+	return questions, nil
+}
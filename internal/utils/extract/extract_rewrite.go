@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"context"
+	"strings"
+
+	"agios/internal/prompts"
+	"agios/internal/utils/constant"
+	"agios/internal/utils/llm"
+)
+
+// RewrittenQuery is a follow-up rewritten to stand on its own.
+type RewrittenQuery struct {
+	Query string `json:"query"`
+}
+
+// RewriteQuery returns query rewritten so that it can be understood without
+// history, the rendered last turns of the conversation. An empty rewrite
+// keeps query.
+func RewriteQuery(ctx context.Context, history, query string) (string, error) {
+	out, err := Extract[RewrittenQuery](llm.WithStage(ctx, constant.StageQueryRewrite), prompts.QueryRewritePrompt, map[string]any{
+		"history": history,
+		"query":   query,
+	})
+	if err != nil {
+		return query, err
+	}
+	if rewritten := strings.TrimSpace(out.Query); rewritten != "" {
+		return rewritten, nil
+	}
+	return query, nil
+}
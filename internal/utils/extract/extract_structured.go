@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"agios/internal/prompts"
+	"agios/internal/utils/jsonschema"
+	"agios/internal/utils/llm"
+
+	langchainprompts "github.com/tmc/langchaingo/prompts"
+)
+
+// Steps of a structured extraction, as reported by ExtractError.
+const (
+	StepPrompt   = "prompt"
+	StepGenerate = "generate"
+	StepLocate   = "locate_json"
+	StepDecode   = "decode"
+	StepValidate = "validate"
+)
+
+// maxExtractAttempts is the first attempt plus one repair attempt.
+const maxExtractAttempts = 2
+
+// ExtractError reports the step at which a structured extraction gave up.
+type ExtractError struct {
+	Step     string
+	Attempts int
+	Details  []string
+	Err      error
+}
+
+func (e *ExtractError) Error() string {
+	msg := fmt.Sprintf("extraction failed at %s after %d attempt(s)", e.Step, e.Attempts)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	if len(e.Details) > 0 {
+		msg += ": " + strings.Join(e.Details, "; ")
+	}
+	return msg
+}
+
+func (e *ExtractError) Unwrap() error {
+	return e.Err
+}
+
+// Extract renders tmpl with values, asks the model for a JSON object and
+// decodes it into T. The output is validated against the schema derived
+// from T; if it is unusable, the errors are sent back to the model for one
+// repair attempt.
+func Extract[T any](ctx context.Context, tmpl langchainprompts.PromptTemplate, values map[string]any) (*T, error) {
+	schema := jsonschema.For[T]()
+	schemaJSON, _ := json.MarshalIndent(schema, "", "  ")
+
+	prompt, err := tmpl.Format(values)
+	if err != nil {
+		return nil, &ExtractError{Step: StepPrompt, Err: err}
+	}
+	originalPrompt := prompt
+	prompt += "\n\nRespond with a single JSON object that matches this JSON schema:\n" + string(schemaJSON)
+
+	var lastErr *ExtractError
+	for attempt := 1; attempt <= maxExtractAttempts; attempt++ {
+		raw, err := llm.GenerateFullResponse(ctx, prompt, nil)
+		if err != nil {
+			// Transport failures were already retried by the llm package;
+			// only unusable output is worth a repair attempt.
+			return nil, &ExtractError{Step: StepGenerate, Attempts: attempt, Err: err}
+		}
+
+		parsed, stepErr := decodeAndValidate[T](raw, schema)
+		if stepErr == nil {
+			return parsed, nil
+		}
+		stepErr.Attempts = attempt
+		lastErr = stepErr
+
+		problems := stepErr.Details
+		if stepErr.Err != nil {
+			problems = append([]string{stepErr.Err.Error()}, problems...)
+		}
+
+		repair, err := prompts.JSONRepairPrompt.Format(map[string]any{
+			"original_prompt": originalPrompt,
+			"previous_output": raw,
+			"errors":          "- " + strings.Join(problems, "\n- "),
+			"schema":          string(schemaJSON),
+		})
+		if err != nil {
+			return nil, &ExtractError{Step: StepPrompt, Attempts: attempt, Err: err}
+		}
+		prompt = repair
+	}
+
+	return nil, lastErr
+}
+
+func decodeAndValidate[T any](raw string, schema *jsonschema.Schema) (*T, *ExtractError) {
+	jsonStr, ok := extractJSONSegment(raw)
+	if !ok {
+		return nil, &ExtractError{Step: StepLocate, Err: errors.New("response contains no JSON object")}
+	}
+
+	var generic any
+	if err := json.Unmarshal([]byte(jsonStr), &generic); err != nil {
+		return nil, &ExtractError{Step: StepDecode, Err: err}
+	}
+
+	if problems := schema.Validate(generic); len(problems) > 0 {
+		return nil, &ExtractError{Step: StepValidate, Details: problems}
+	}
+
+	var parsed T
+	if err := json.Unmarshal([]byte(jsonStr), &parsed); err != nil {
+		return nil, &ExtractError{Step: StepDecode, Err: err}
+	}
+
+	return &parsed, nil
+}
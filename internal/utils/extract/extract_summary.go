@@ -2,11 +2,9 @@ package utils
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
-	"fmt"
 
 	"agios/internal/prompts"
+	"agios/internal/utils/constant"
 	"agios/internal/utils/llm"
 )
 
@@ -30,50 +28,9 @@ type ExtractionOutput struct {
 	Metrics            []Metric      `json:"metrics"`
 }
 
-// tryParseLLMOutput tries to parse the LLM output into ExtractionOutput.
-func tryParseSummaryLLMOutput(raw string) (*ExtractionOutput, bool) {
-	jsonStr, ok := extractJSONSegment(raw)
-	if !ok {
-		return nil, false
-	}
-
-	var parsed ExtractionOutput
-	err := json.Unmarshal([]byte(jsonStr), &parsed)
-	if err != nil {
-		return nil, false
-	}
-
-	return &parsed, true
-}
-
-// ExtractTakeaways sends input text to the LLM and tries to parse structured output.
+// ExtractTakeaways sends input text to the LLM and parses its structured output.
 func ExtractTakeaways(ctx context.Context, input string) (*ExtractionOutput, error) {
-	prompt, err := prompts.SummaryPrompt.Format(map[string]any{
+	return Extract[ExtractionOutput](llm.WithStage(ctx, constant.StageTakeaways), prompts.SummaryPrompt, map[string]any{
 		"input_text": input,
 	})
-	if err != nil {
-		return nil, err
-	}
-
-	// Use the new GenerateFullResponse function with retry logic
-	var result string
-	var llmErr error // Use a different variable name for the LLM error
-	for attempt := 0; attempt < 2; attempt++ {
-		result, llmErr = llm.GenerateFullResponse(ctx, prompt, nil)
-		if llmErr == nil {
-			// Attempt to parse the result if LLM call was successful
-			parsed, ok := tryParseSummaryLLMOutput(result)
-			if ok {
-				return parsed, nil
-			}
-			// If parsing fails, continue to the next attempt
-		}
-		// If LLM call failed, or parsing failed, continue to the next attempt
-	}
-
-	// If both attempts failed to produce a parsable result
-	if llmErr != nil {
-		return nil, fmt.Errorf("failed to generate text after multiple attempts: %w", llmErr)
-	}
-	return nil, errors.New("failed to parse LLM output into structured data after multiple attempts")
 }
@@ -2,59 +2,122 @@ package utils
 
 import (
 	"agios/internal/prompts"
+	"agios/internal/utils/constant"
+	"agios/internal/utils/jsonschema"
 	"agios/internal/utils/llm"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 )
 
-type ToolType struct {
-	ToolType string `json:"tool_type"`
+// ToolSpec describes one tool the detector may call.
+type ToolSpec struct {
+	Name        string
+	Description string
+	Parameters  *jsonschema.Schema
+	// Examples show the detector queries the tool answers.
+	Examples []ToolExample
 }
 
-func tryParseSearchToolOutput(raw string) (*ToolType, bool) {
-	jsonStr, ok := extractJSONSegment(raw)
-
-	if !ok {
-		return nil, false
-	}
+// ToolExample is a query together with the arguments the tool should be
+// called with for it.
+type ToolExample struct {
+	Query string
+	Args  map[string]any
+}
 
-	var parsed ToolType
-	err := json.Unmarshal([]byte(jsonStr), &parsed)
+// ToolCatalog describes the tools the detector may choose from.
+type ToolCatalog struct {
+	Tools   []ToolSpec
+	Default string
+}
 
-	if err != nil {
-		return nil, false
-	}
-	return &parsed, true
+// ToolType is the detected tool together with the typed arguments the model
+// supplied for it.
+type ToolType struct {
+	Tool   string         `json:"tool"`
+	Params map[string]any `json:"params"`
 }
 
-func ExtractToolType(ctx context.Context, text string) (*ToolType, error) {
-	formattedPrompt, err := prompts.ToolDetectorPrompt.Format(map[string]any{"user_query": text})
+// ExtractToolType asks the model to pick a tool from catalog by calling it as
+// a function, so the parameters come back as structured arguments.
+func ExtractToolType(ctx context.Context, text string, catalog ToolCatalog) (*ToolType, error) {
+	formattedPrompt, err := prompts.ToolDetectorPrompt.Format(map[string]any{
+		"default_tool": catalog.Default,
+		"examples":     catalog.examples(),
+		"user_query":   text,
+	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	// Use the new GenerateFullResponse function with retry logic
-	var result string
-	var llmErr error // Use a different variable name for the LLM error
+	funcs := make([]llm.Function, len(catalog.Tools))
+	for i, t := range catalog.Tools {
+		funcs[i] = llm.Function{Name: t.Name, Description: t.Description, Parameters: t.Parameters}
+	}
+
+	// Only answers that are not a usable call are asked for again;
+	// transport failures were already retried by the llm package.
+	var problem error
 	for attempt := 0; attempt < 2; attempt++ {
-		result, llmErr = llm.GenerateFullResponse(ctx, formattedPrompt, nil)
-		if llmErr == nil {
-			// Attempt to parse the result if LLM call was successful
-			parsed, ok := tryParseSearchToolOutput(result)
-			if ok {
-				return parsed, nil
+		call, err := llm.GenerateFunctionCall(llm.WithStage(ctx, constant.StageToolDetection), formattedPrompt, funcs)
+		if err != nil && !errors.Is(err, llm.ErrNoFunctionCall) && !errors.Is(err, llm.ErrInvalidArguments) {
+			return nil, fmt.Errorf("failed to detect tool: %w", err)
+		}
+		if err == nil {
+			if err = catalog.check(call); err == nil {
+				params := call.Args
+				if params == nil {
+					params = map[string]any{}
+				}
+				return &ToolType{Tool: call.Name, Params: params}, nil
 			}
-			// If parsing fails, continue to the next attempt
 		}
-		// If LLM call failed, or parsing failed, continue to the next attempt
+		problem = err
 	}
 
-	// If both attempts failed to produce a parsable result
-	if llmErr != nil {
-		return nil, fmt.Errorf("failed to generate text after multiple attempts: %w", llmErr)
+	return nil, fmt.Errorf("failed to detect tool after multiple attempts: %w", problem)
+}
+
+// examples renders the examples of every tool for the detector prompt.
+func (c ToolCatalog) examples() string {
+	var blocks []string
+	for _, t := range c.Tools {
+		for _, ex := range t.Examples {
+			names := make([]string, 0, len(ex.Args))
+			for name := range ex.Args {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			args := make([]string, len(names))
+			for i, name := range names {
+				value, _ := json.Marshal(ex.Args[name])
+				args[i] = name + "=" + string(value)
+			}
+			blocks = append(blocks, fmt.Sprintf("User Query: %q\nCall: %s(%s)", ex.Query, t.Name, strings.Join(args, ", ")))
+		}
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// check reports whether call names a tool of the catalog with arguments
+// matching its parameters.
+func (c ToolCatalog) check(call *llm.FunctionCall) error {
+	for _, t := range c.Tools {
+		if t.Name != call.Name {
+			continue
+		}
+		if t.Parameters == nil {
+			return nil
+		}
+		if problems := t.Parameters.Validate(call.Args); len(problems) > 0 {
+			return fmt.Errorf("%w for %s: %s", llm.ErrInvalidArguments, call.Name, strings.Join(problems, "; "))
+		}
+		return nil
 	}
-	return nil, errors.New("failed to parse LLM output into structured data after multiple attempts")
+	return fmt.Errorf("model called unknown tool %q", call.Name)
 }
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"agios/internal/prompts"
+)
+
+func TestToolCatalogExamples(t *testing.T) {
+	catalog := ToolCatalog{
+		Default: "general_search",
+		Tools: []ToolSpec{
+			{Name: "general_search", Examples: []ToolExample{{Query: "Tell me about Go."}}},
+			{Name: "weather_forecast"},
+			{Name: "nearby_businesses", Examples: []ToolExample{
+				{Query: `Find a "Blue Bottle" downtown.`, Args: map[string]any{"location": "downtown", "keyword": "Blue Bottle"}},
+			}},
+		},
+	}
+
+	want := "User Query: \"Tell me about Go.\"\nCall: general_search()\n\n" +
+		"User Query: \"Find a \\\"Blue Bottle\\\" downtown.\"\nCall: nearby_businesses(keyword=\"Blue Bottle\", location=\"downtown\")"
+	if got := catalog.examples(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	prompt, err := prompts.ToolDetectorPrompt.Format(map[string]any{
+		"default_tool": catalog.Default,
+		"examples":     catalog.examples(),
+		"user_query":   "Is it raining?",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(prompt, "<example_queries>\n"+want+"\n</example_queries>") {
+		t.Errorf("examples missing from prompt:\n%s", prompt)
+	}
+
+	prompt, err = prompts.ToolDetectorPrompt.Format(map[string]any{
+		"default_tool": catalog.Default,
+		"examples":     ToolCatalog{}.examples(),
+		"user_query":   "Is it raining?",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(prompt, "example_queries") {
+		t.Errorf("prompt without examples has an example block:\n%s", prompt)
+	}
+}
@@ -2,13 +2,14 @@ package utils
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
+
+	"agios/internal/utils/constant"
 )
 
 // DailySummary represents basic daily weather data.
@@ -90,26 +91,10 @@ func GetWeatherForecastWithConfig(ctx context.Context, city string, latitude, lo
 	params.Set("forecast_days", fmt.Sprintf("%d", config.ForecastDays))
 
 	// HTTP request
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, forecastAPIURL+"?"+params.Encode(), nil)
-	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
-	}
-
 	client := &http.Client{Timeout: config.Timeout}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("forecast request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("forecast API returned status %d", resp.StatusCode)
-	}
-
-	// Response payload
 	var payload forecastResponse
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		return nil, fmt.Errorf("decoding response: %w", err)
+	if err := getCachedJSON(ctx, constant.StageWeather, "open_meteo", client, forecastAPIURL+"?"+params.Encode(), &payload); err != nil {
+		return nil, fmt.Errorf("forecast request failed: %w", err)
 	}
 
 	dates := payload.Daily.Time
@@ -180,6 +165,15 @@ type forecastResponse struct {
 	} `json:"hourly"`
 }
 
+// GeocodeCity resolves a city name to coordinates using the Open-Meteo geocoding API.
+func GeocodeCity(ctx context.Context, city string) (float64, float64, error) {
+	coords, err := geocodeWithTimeout(ctx, city, defaultTimeout)
+	if err != nil {
+		return 0, 0, err
+	}
+	return coords.Lat, coords.Lon, nil
+}
+
 // geocodeResult holds geocoding response.
 type geocodeResult struct {
 	Results []struct {
@@ -194,26 +188,10 @@ type geocodeResult struct {
 func geocodeWithTimeout(ctx context.Context, city string, timeout time.Duration) (struct{ Lat, Lon float64 }, error) {
 	apiURL := fmt.Sprintf("%s?name=%s&count=1", geocodeAPIURL, url.QueryEscape(city))
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
-	if err != nil {
-		return struct{ Lat, Lon float64 }{}, fmt.Errorf("creating geocode request: %w", err)
-	}
-
 	client := &http.Client{Timeout: timeout}
-	resp, err := client.Do(req)
-	if err != nil {
-		return struct{ Lat, Lon float64 }{}, fmt.Errorf("geocode request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Check for HTTP errors
-	if resp.StatusCode >= 400 {
-		return struct{ Lat, Lon float64 }{}, fmt.Errorf("geocoding API returned status %d", resp.StatusCode)
-	}
-
 	var res geocodeResult
-	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
-		return struct{ Lat, Lon float64 }{}, fmt.Errorf("decoding geocode response: %w", err)
+	if err := getCachedJSON(ctx, constant.StageGeocode, "open_meteo", client, apiURL, &res); err != nil {
+		return struct{ Lat, Lon float64 }{}, fmt.Errorf("geocode request failed: %w", err)
 	}
 
 	if len(res.Results) == 0 {
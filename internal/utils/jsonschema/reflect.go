@@ -0,0 +1,72 @@
+package jsonschema
+
+import (
+	"reflect"
+	"strings"
+)
+
+// For derives the schema of T from its Go type. Struct fields follow their
+// json tags; fields without omitempty and not behind a pointer are required.
+// A `description` struct tag becomes the property description.
+func For[T any]() *Schema {
+	return FromType(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+// FromType derives the schema of t. See For.
+func FromType(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return FromType(t.Elem())
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: FromType(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Struct:
+		s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		addFields(s, t)
+		return s
+	default:
+		return &Schema{}
+	}
+}
+
+func addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			addFields(s, f.Type)
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := FromType(f.Type)
+		prop.Description = f.Tag.Get("description")
+		s.Properties[name] = prop
+
+		if f.Type.Kind() != reflect.Ptr && !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package jsonschema
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Validate checks a value decoded with encoding/json (maps, slices, float64,
+// string, bool, nil) against s. It returns one message per violation, each
+// prefixed with the path of the offending value.
+func (s *Schema) Validate(v any) []string {
+	var errs []string
+	s.validate("$", v, &errs)
+	return errs
+}
+
+func (s *Schema) validate(path string, v any, errs *[]string) {
+	if s == nil || s.Type == "" {
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			*errs = append(*errs, fmt.Sprintf("%s: expected object, got %s", path, typeName(v)))
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				*errs = append(*errs, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if val, ok := obj[name]; ok {
+				s.Properties[name].validate(path+"."+name, val, errs)
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			*errs = append(*errs, fmt.Sprintf("%s: expected array, got %s", path, typeName(v)))
+			return
+		}
+		for i, item := range arr {
+			s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			*errs = append(*errs, fmt.Sprintf("%s: expected string, got %s", path, typeName(v)))
+			return
+		}
+		if len(s.Enum) > 0 && !contains(s.Enum, str) {
+			*errs = append(*errs, fmt.Sprintf("%s: %q is not one of %v", path, str, s.Enum))
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			*errs = append(*errs, fmt.Sprintf("%s: expected number, got %s", path, typeName(v)))
+		}
+	case "integer":
+		n, ok := v.(float64)
+		if !ok || n != math.Trunc(n) {
+			*errs = append(*errs, fmt.Sprintf("%s: expected integer, got %s", path, typeName(v)))
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			*errs = append(*errs, fmt.Sprintf("%s: expected boolean, got %s", path, typeName(v)))
+		}
+	}
+}
+
+func typeName(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
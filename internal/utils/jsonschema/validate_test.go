@@ -0,0 +1,109 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type place struct {
+	Name     string   `json:"name"`
+	Kind     string   `json:"kind"`
+	Rating   float64  `json:"rating"`
+	Reviews  int      `json:"reviews"`
+	Open     bool     `json:"open"`
+	Tags     []string `json:"tags,omitempty"`
+	Location *struct {
+		Lat float64 `json:"lat"`
+		Lng float64 `json:"lng"`
+	} `json:"location"`
+}
+
+func TestValidate(t *testing.T) {
+	schema := For[place]()
+	schema.Properties["kind"].Enum = []string{"cafe", "bar"}
+
+	tests := []struct {
+		name string
+		doc  string
+		want []string
+	}{
+		{
+			name: "valid",
+			doc:  `{"name": "Blue", "kind": "cafe", "rating": 4.5, "reviews": 10, "open": true, "tags": ["wifi"], "location": {"lat": 1, "lng": 2}}`,
+		},
+		{
+			name: "optional fields left out",
+			doc:  `{"name": "Blue", "kind": "bar", "rating": 4, "reviews": 0, "open": false}`,
+		},
+		{
+			name: "missing required",
+			doc:  `{"name": "Blue", "kind": "cafe", "rating": 4.5}`,
+			want: []string{`$: missing required property "reviews"`, `$: missing required property "open"`},
+		},
+		{
+			name: "wrong types",
+			doc:  `{"name": 7, "kind": "cafe", "rating": "high", "reviews": 1.5, "open": "yes"}`,
+			want: []string{
+				"$.name: expected string, got integer",
+				"$.open: expected boolean, got string",
+				"$.rating: expected number, got string",
+				"$.reviews: expected integer, got number",
+			},
+		},
+		{
+			name: "enum",
+			doc:  `{"name": "Blue", "kind": "pub", "rating": 4, "reviews": 1, "open": true}`,
+			want: []string{`$.kind: "pub" is not one of [cafe bar]`},
+		},
+		{
+			name: "nested",
+			doc:  `{"name": "Blue", "kind": "cafe", "rating": 4, "reviews": 1, "open": true, "tags": ["a", 2], "location": {"lat": "north"}}`,
+			want: []string{
+				`$.location: missing required property "lng"`,
+				"$.location.lat: expected number, got string",
+				"$.tags[1]: expected string, got integer",
+			},
+		},
+		{
+			name: "not an object",
+			doc:  `["Blue"]`,
+			want: []string{"$: expected object, got array"},
+		},
+		{
+			name: "null",
+			doc:  `null`,
+			want: []string{"$: expected object, got null"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v any
+			if err := json.Unmarshal([]byte(tt.doc), &v); err != nil {
+				t.Fatal(err)
+			}
+			if got := schema.Validate(v); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFor(t *testing.T) {
+	schema := For[place]()
+
+	wantRequired := []string{"name", "kind", "rating", "reviews", "open"}
+	if !reflect.DeepEqual(schema.Required, wantRequired) {
+		t.Errorf("required = %v, want %v", schema.Required, wantRequired)
+	}
+	if got := schema.Properties["reviews"].Type; got != "integer" {
+		t.Errorf("reviews type = %q, want integer", got)
+	}
+	if got := schema.Properties["tags"].Items.Type; got != "string" {
+		t.Errorf("tags item type = %q, want string", got)
+	}
+	if got := schema.Properties["location"].Type; got != "object" {
+		t.Errorf("location type = %q, want object", got)
+	}
+}
@@ -0,0 +1,281 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"agios/internal/config"
+)
+
+// CacheMode controls how a request uses the response cache.
+type CacheMode string
+
+const (
+	// CacheDefault reads and fills the cache.
+	CacheDefault CacheMode = ""
+	// CacheRefresh skips reading but stores the fresh response.
+	CacheRefresh CacheMode = "refresh"
+	// CacheBypass neither reads nor writes the cache.
+	CacheBypass CacheMode = "bypass"
+)
+
+// ParseCacheMode reads the value of the X-LLM-Cache header. Unknown values
+// give CacheDefault.
+func ParseCacheMode(v string) CacheMode {
+	switch mode := CacheMode(strings.ToLower(strings.TrimSpace(v))); mode {
+	case CacheRefresh, CacheBypass:
+		return mode
+	default:
+		return CacheDefault
+	}
+}
+
+type cacheModeKey struct{}
+
+// WithCacheMode returns a context under which LLM calls use the cache as mode
+// says.
+func WithCacheMode(ctx context.Context, mode CacheMode) context.Context {
+	return context.WithValue(ctx, cacheModeKey{}, mode)
+}
+
+const (
+	cacheKeyPrefix = "agios:llmcache:"
+	cacheStatsKey  = "agios:llmcache:stats"
+)
+
+// responseCache stores LLM responses in Redis by the exact request.
+type responseCache struct {
+	rdb *redis.Client
+	cfg *config.Config
+}
+
+var (
+	cacheMu sync.RWMutex
+	cache   *responseCache
+)
+
+// EnableCache caches LLM responses in rdb as configured by cfg.LLMCache and
+// its TTLs. It is called once at startup.
+func EnableCache(rdb *redis.Client, cfg *config.Config) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	if cfg.LLMCache && rdb != nil {
+		cache = &responseCache{rdb: rdb, cfg: cfg}
+	}
+}
+
+// cachedResponse is what is stored for a request.
+type cachedResponse struct {
+	Text string        `json:"text,omitempty"`
+	Call *FunctionCall `json:"call,omitempty"`
+}
+
+// cacheEntry is the cache slot of one request. A nil entry means the request
+// is not cached.
+type cacheEntry struct {
+	cache *responseCache
+	key   string
+	stage string
+	ttl   time.Duration
+}
+
+// cacheFor returns the slot of a request of kind ("generate", "stream",
+// "function" or "tool") for model, or nil when the request is not cached.
+func cacheFor(ctx context.Context, kind, model string, parts []Part, funcs []Function) *cacheEntry {
+	cacheMu.RLock()
+	c := cache
+	cacheMu.RUnlock()
+	if c == nil {
+		return nil
+	}
+
+	mode, _ := ctx.Value(cacheModeKey{}).(CacheMode)
+	if mode == CacheBypass {
+		return nil
+	}
+
+	stage := stageOf(ctx)
+	ttl := c.cfg.CacheTTL(stage)
+	if ttl <= 0 {
+		return nil
+	}
+
+	return &cacheEntry{
+		cache: c,
+		key:   cacheKeyPrefix + requestHash(kind, model, parts, funcs),
+		stage: stage,
+		ttl:   ttl,
+	}
+}
+
+// Cached returns the result of fn for key from the cache slot of stage,
+// calling fn and storing its result on a miss. Tools use it for the calls
+// they pay for, such as web search and Google Places, with the cache mode,
+// TTLs and hit and miss counters of LLM calls. key must encode to JSON and
+// the result must survive a JSON round trip; errors are not cached.
+func Cached[T any](ctx context.Context, stage string, key any, fn func(context.Context) (T, error)) (T, error) {
+	entry := cacheFor(WithStage(ctx, stage), "tool", stage, []Part{TextPart(toolKey(key))}, nil)
+	if entry == nil {
+		return fn(ctx)
+	}
+
+	var cached T
+	if entry.load(ctx, &cached) {
+		return cached, nil
+	}
+
+	v, err := fn(ctx)
+	if err == nil {
+		entry.store(ctx, v)
+	}
+	return v, err
+}
+
+// toolKey is the JSON text of a tool call key.
+func toolKey(key any) string {
+	raw, _ := json.Marshal(key)
+	return string(raw)
+}
+
+// requestHash identifies a request by its kind, model, parts and functions.
+// Inline data is represented by its own hash.
+func requestHash(kind, model string, parts []Part, funcs []Function) string {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	_ = enc.Encode([]string{kind, model})
+	for _, p := range parts {
+		data := ""
+		if p.Data != nil {
+			sum := sha256.Sum256(p.Data)
+			data = hex.EncodeToString(sum[:])
+		}
+		_ = enc.Encode([]string{p.Text, p.MIMEType, data, p.URI})
+	}
+	for _, f := range funcs {
+		_ = enc.Encode(f)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// get returns the cached response, counting a hit or a miss. A refreshing
+// request always misses.
+func (e *cacheEntry) get(ctx context.Context) (*cachedResponse, bool) {
+	var resp cachedResponse
+	if !e.load(ctx, &resp) {
+		return nil, false
+	}
+	return &resp, true
+}
+
+func (e *cacheEntry) put(ctx context.Context, resp cachedResponse) {
+	e.store(ctx, resp)
+}
+
+// load decodes the cached value into out, counting a hit or a miss. A
+// refreshing request always misses.
+func (e *cacheEntry) load(ctx context.Context, out any) bool {
+	if mode, _ := ctx.Value(cacheModeKey{}).(CacheMode); mode == CacheRefresh {
+		return false
+	}
+
+	raw, err := e.cache.rdb.Get(ctx, e.key).Bytes()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		log.Printf("LLM cache read failed: %v", err)
+	}
+
+	hit := err == nil && json.Unmarshal(raw, out) == nil
+	e.count(ctx, hit)
+	return hit
+}
+
+func (e *cacheEntry) store(ctx context.Context, v any) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	if err := e.cache.rdb.Set(context.WithoutCancel(ctx), e.key, raw, e.ttl).Err(); err != nil {
+		log.Printf("LLM cache write failed: %v", err)
+	}
+}
+
+func (e *cacheEntry) count(ctx context.Context, hit bool) {
+	field := e.stage + ":misses"
+	if hit {
+		field = e.stage + ":hits"
+	}
+	if err := e.cache.rdb.HIncrBy(context.WithoutCancel(ctx), cacheStatsKey, field, 1).Err(); err != nil {
+		log.Printf("LLM cache stats update failed: %v", err)
+	}
+}
+
+// CacheCounts are the hits and misses of the cache for one stage.
+type CacheCounts struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// CacheStats returns the hit and miss counters per stage, or nil when the
+// cache is off.
+func CacheStats(ctx context.Context) (map[string]CacheCounts, error) {
+	cacheMu.RLock()
+	c := cache
+	cacheMu.RUnlock()
+	if c == nil {
+		return nil, nil
+	}
+
+	fields, err := c.rdb.HGetAll(ctx, cacheStatsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := map[string]CacheCounts{}
+	for field, v := range fields {
+		stage, kind, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+		n, _ := strconv.ParseInt(v, 10, 64)
+		counts := stats[stage]
+		switch kind {
+		case "hits":
+			counts.Hits = n
+		case "misses":
+			counts.Misses = n
+		}
+		stats[stage] = counts
+	}
+	return stats, nil
+}
+
+// cachingStream passes a stream through and stores its text once it has
+// been read to the end.
+type cachingStream struct {
+	ChunkStream
+	ctx   context.Context
+	entry *cacheEntry
+	text  strings.Builder
+}
+
+func (s *cachingStream) Next() (string, error) {
+	chunk, err := s.ChunkStream.Next()
+	if err == nil {
+		s.text.WriteString(chunk)
+	}
+	if errors.Is(err, io.EOF) && s.text.Len() > 0 {
+		s.entry.put(s.ctx, cachedResponse{Text: s.text.String()})
+	}
+	return chunk, err
+}
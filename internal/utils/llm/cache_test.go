@@ -0,0 +1,147 @@
+package llm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"agios/internal/config"
+	"agios/internal/utils/jsonschema"
+)
+
+// withCache enables the response cache on an in-memory Redis for the
+// duration of a test. The "uncached" stage has a zero TTL.
+func withCache(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	cacheMu.Lock()
+	cache = &responseCache{rdb: rdb, cfg: &config.Config{
+		LLMCache:          true,
+		LLMCacheTTL:       time.Hour,
+		LLMCacheStageTTLs: map[string]time.Duration{"uncached": 0},
+	}}
+	cacheMu.Unlock()
+
+	t.Cleanup(func() {
+		cacheMu.Lock()
+		cache = nil
+		cacheMu.Unlock()
+	})
+	return mr
+}
+
+func TestRequestHash(t *testing.T) {
+	schema := func(prop string) []Function {
+		return []Function{{Name: "search", Parameters: &jsonschema.Schema{
+			Type:       "object",
+			Properties: map[string]*jsonschema.Schema{prop: {Type: "string"}},
+		}}}
+	}
+	base := requestHash("generate", "gemini-2.0-flash", []Part{TextPart("hello"), DataPart("image/png", []byte{1, 2})}, schema("query"))
+
+	if again := requestHash("generate", "gemini-2.0-flash", []Part{TextPart("hello"), DataPart("image/png", []byte{1, 2})}, schema("query")); again != base {
+		t.Errorf("the same request hashed to %s and %s", base, again)
+	}
+
+	tests := []struct {
+		name  string
+		kind  string
+		model string
+		parts []Part
+		funcs []Function
+	}{
+		{"kind", "stream", "gemini-2.0-flash", []Part{TextPart("hello"), DataPart("image/png", []byte{1, 2})}, schema("query")},
+		{"model", "generate", "gemini-2.5-pro", []Part{TextPart("hello"), DataPart("image/png", []byte{1, 2})}, schema("query")},
+		{"text", "generate", "gemini-2.0-flash", []Part{TextPart("hello!"), DataPart("image/png", []byte{1, 2})}, schema("query")},
+		{"data", "generate", "gemini-2.0-flash", []Part{TextPart("hello"), DataPart("image/png", []byte{1, 3})}, schema("query")},
+		{"mime type", "generate", "gemini-2.0-flash", []Part{TextPart("hello"), DataPart("image/jpeg", []byte{1, 2})}, schema("query")},
+		{"part split", "generate", "gemini-2.0-flash", []Part{TextPart("hel"), TextPart("lo"), DataPart("image/png", []byte{1, 2})}, schema("query")},
+		{"schema", "generate", "gemini-2.0-flash", []Part{TextPart("hello"), DataPart("image/png", []byte{1, 2})}, schema("location")},
+		{"no functions", "generate", "gemini-2.0-flash", []Part{TextPart("hello"), DataPart("image/png", []byte{1, 2})}, nil},
+	}
+	for _, tt := range tests {
+		if got := requestHash(tt.kind, tt.model, tt.parts, tt.funcs); got == base {
+			t.Errorf("changing the %s kept the hash", tt.name)
+		}
+	}
+}
+
+func TestCacheFor(t *testing.T) {
+	ctx := WithStage(context.Background(), "synthesis")
+	parts := []Part{TextPart("hello")}
+
+	if e := cacheFor(ctx, "generate", "m", parts, nil); e != nil {
+		t.Error("a request was cached with the cache off")
+	}
+
+	withCache(t)
+	if e := cacheFor(ctx, "generate", "m", parts, nil); e == nil || e.ttl != time.Hour || e.stage != "synthesis" {
+		t.Errorf("entry = %+v, want the synthesis stage for an hour", e)
+	}
+	if e := cacheFor(WithCacheMode(ctx, CacheBypass), "generate", "m", parts, nil); e != nil {
+		t.Error("a bypassing request was cached")
+	}
+	if e := cacheFor(WithCacheMode(ctx, CacheRefresh), "generate", "m", parts, nil); e == nil {
+		t.Error("a refreshing request has no slot to store into")
+	}
+	if e := cacheFor(WithStage(ctx, "uncached"), "generate", "m", parts, nil); e != nil {
+		t.Error("a stage with a zero TTL was cached")
+	}
+}
+
+func TestCached(t *testing.T) {
+	mr := withCache(t)
+	ctx := context.Background()
+
+	calls := 0
+	lookup := func(ctx context.Context, mode CacheMode) string {
+		v, err := Cached(WithCacheMode(ctx, mode), "web_search", []any{"searxng", "paris"}, func(ctx context.Context) (string, error) {
+			calls++
+			return "result " + string(rune('0'+calls)), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	steps := []struct {
+		name      string
+		mode      CacheMode
+		want      string
+		wantCalls int
+	}{
+		{"miss", CacheDefault, "result 1", 1},
+		{"hit", CacheDefault, "result 1", 1},
+		{"bypass", CacheBypass, "result 2", 2},
+		{"hit after bypass", CacheDefault, "result 1", 2},
+		{"refresh", CacheRefresh, "result 3", 3},
+		{"hit after refresh", CacheDefault, "result 3", 3},
+	}
+	for _, s := range steps {
+		if got := lookup(ctx, s.mode); got != s.want || calls != s.wantCalls {
+			t.Fatalf("%s: got %q after %d calls, want %q after %d", s.name, got, calls, s.want, s.wantCalls)
+		}
+	}
+
+	stats, err := CacheStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only reads are counted: the bypass and the refresh skip them.
+	if got := stats["web_search"]; got != (CacheCounts{Hits: 3, Misses: 1}) {
+		t.Errorf("counts = %+v, want 3 hits and 1 miss", got)
+	}
+
+	mr.FastForward(2 * time.Hour)
+	if got := lookup(ctx, CacheDefault); got != "result 4" {
+		t.Errorf("expired entry gave %q, want a fresh result", got)
+	}
+}
@@ -2,31 +2,43 @@ package llm
 
 import (
 	"context"
-	"log"
-	"os"
+	"fmt"
 
-	"github.com/tmc/langchaingo/llms/googleai"
+	"agios/internal/utils/resilience"
 )
 
-// CreateEmbedding creates embeddings for the given texts using the Gemini API.
-func CreateEmbedding(ctx context.Context, texts []string) ([][]float32, string) {
-	apiKey := os.Getenv("GOOGLE_API_KEY")
-	if apiKey == "" {
-		log.Println("GOOGLE_API_KEY not set")
-		return nil, ""
-	}
+// Embedder is implemented by providers that can embed text.
+type Embedder interface {
+	// Embed returns one vector per text, in order.
+	Embed(ctx context.Context, model string, texts []string) ([][]float32, error)
+}
 
-	llm, err := googleai.New(ctx, googleai.WithAPIKey(apiKey))
+// CreateEmbedding embeds texts with EMBEDDING_MODEL, retrying transient
+// failures like any other call to the model.
+func CreateEmbedding(ctx context.Context, texts []string) ([][]float32, error) {
+	cfg, err := currentConfig()
 	if err != nil {
-		log.Printf("error creating LLM: %v", err)
-		return nil, ""
+		return nil, err
 	}
+	model := cfg.EmbeddingModel
 
-	emb, err := llm.CreateEmbedding(ctx, texts)
-	if err != nil {
-		log.Printf("error creating embeddings: %v", err)
-		return nil, ""
-	}
+	return resilience.DoValue(ctx, "llm:"+model, func(ctx context.Context) ([][]float32, error) {
+		provider, providerModel, err := resolve(model)
+		if err != nil {
+			return nil, err
+		}
+		embedder, ok := provider.(Embedder)
+		if !ok {
+			return nil, fmt.Errorf("LLM provider %s cannot create embeddings", provider.Name())
+		}
 
-	return emb, ""
+		vectors, err := embedder.Embed(ctx, providerModel, texts)
+		if err != nil {
+			return nil, err
+		}
+		if len(vectors) != len(texts) {
+			return nil, fmt.Errorf("%s returned %d embeddings for %d texts", model, len(vectors), len(texts))
+		}
+		return vectors, nil
+	})
 }
@@ -0,0 +1,183 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// FakeRule scripts the fake provider's answer to prompts containing Match.
+// A rule with Call answers function-calling requests; one with Reply answers
+// generation requests.
+type FakeRule struct {
+	Match string        `json:"match"`
+	Reply string        `json:"reply,omitempty"`
+	Call  *FunctionCall `json:"call,omitempty"`
+}
+
+// FakeScript is the JSON document FAKE_LLM_SCRIPT points to.
+type FakeScript struct {
+	Rules   []FakeRule `json:"rules"`
+	Default string     `json:"default"`
+}
+
+// fakeProvider answers deterministically from a script, so the pipeline can
+// run without network access. Token usage is counted in words.
+type fakeProvider struct {
+	script FakeScript
+}
+
+// NewFakeProvider returns the scripted "fake" provider. Rules are tried in
+// order and the first match wins; prompts matching no reply rule get
+// script.Default, and function calls matching no rule call the first
+// function without arguments.
+func NewFakeProvider(script FakeScript) Provider {
+	if script.Default == "" {
+		script.Default = "This is a scripted answer."
+	}
+	return &fakeProvider{script: script}
+}
+
+// LoadFakeProvider reads a FakeScript from path. An empty path gives the
+// fake provider with no rules.
+func LoadFakeProvider(path string) (Provider, error) {
+	var script FakeScript
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &script); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	}
+	return NewFakeProvider(script), nil
+}
+
+func (p *fakeProvider) Name() string { return "fake" }
+
+func (p *fakeProvider) Generate(ctx context.Context, req Request) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	prompt := promptText(req.Parts)
+	reply := p.reply(prompt)
+	return &Response{Text: reply, Usage: wordUsage(prompt, reply)}, nil
+}
+
+func (p *fakeProvider) Stream(ctx context.Context, req Request) (ChunkStream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	prompt := promptText(req.Parts)
+	reply := p.reply(prompt)
+	return &textStream{
+		ctx:    ctx,
+		chunks: strings.SplitAfter(reply, " "),
+		usage:  wordUsage(prompt, reply),
+	}, nil
+}
+
+func (p *fakeProvider) CallFunction(ctx context.Context, req Request, funcs []Function) (*FunctionCall, TokenUsage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, TokenUsage{}, err
+	}
+	if len(funcs) == 0 {
+		return nil, TokenUsage{}, ErrNoFunctionCall
+	}
+
+	prompt := promptText(req.Parts)
+	offered := func(name string) bool {
+		return slices.ContainsFunc(funcs, func(f Function) bool { return f.Name == name })
+	}
+
+	call := &FunctionCall{Name: funcs[0].Name, Args: map[string]any{}}
+	for _, r := range p.script.Rules {
+		if r.Call != nil && strings.Contains(prompt, r.Match) && offered(r.Call.Name) {
+			call = r.Call
+			break
+		}
+	}
+
+	args, _ := json.Marshal(call.Args)
+	return call, wordUsage(prompt, call.Name+" "+string(args)), nil
+}
+
+// fakeEmbeddingDims is the size of the fake provider's embeddings.
+const fakeEmbeddingDims = 64
+
+// Embed hashes the words of each text into a vector, so texts sharing
+// words are similar.
+func (p *fakeProvider) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, fakeEmbeddingDims)
+		for _, word := range strings.Fields(strings.ToLower(text)) {
+			h := fnv.New32a()
+			h.Write([]byte(word))
+			v[h.Sum32()%fakeEmbeddingDims]++
+		}
+		vectors[i] = v
+	}
+	return vectors, nil
+}
+
+func (p *fakeProvider) reply(prompt string) string {
+	for _, r := range p.script.Rules {
+		if r.Call == nil && strings.Contains(prompt, r.Match) {
+			return r.Reply
+		}
+	}
+	return p.script.Default
+}
+
+// textStream yields a fixed text word by word.
+type textStream struct {
+	ctx    context.Context
+	chunks []string
+	usage  TokenUsage
+}
+
+func (s *textStream) Next() (string, error) {
+	if err := s.ctx.Err(); err != nil {
+		return "", err
+	}
+	for len(s.chunks) > 0 {
+		chunk := s.chunks[0]
+		s.chunks = s.chunks[1:]
+		if chunk != "" {
+			return chunk, nil
+		}
+	}
+	return "", io.EOF
+}
+
+func (s *textStream) Usage() TokenUsage { return s.usage }
+
+func (s *textStream) Close() error { return nil }
+
+// promptText joins the text parts of a prompt.
+func promptText(parts []Part) string {
+	var texts []string
+	for _, p := range parts {
+		if p.IsText() {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+func wordUsage(prompt, reply string) TokenUsage {
+	return TokenUsage{InputTokens: len(strings.Fields(prompt)), OutputTokens: len(strings.Fields(reply))}
+}
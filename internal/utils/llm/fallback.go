@@ -0,0 +1,37 @@
+package llm
+
+import (
+	"context"
+	"log"
+
+	"agios/internal/utils/resilience"
+)
+
+// withFallbacks calls fn with model, retrying it while its failures are
+// transient, and then with each fallback configured for model while the
+// previous one stays unavailable. It returns the result and the model that
+// produced it. Every model is a separate dependency with its own circuit
+// breaker.
+func withFallbacks[T any](ctx context.Context, model string, fn func(ctx context.Context, model string) (T, error)) (T, string, error) {
+	chain := []string{model}
+	if cfg, err := currentConfig(); err == nil {
+		chain = cfg.ModelChain(model)
+	}
+
+	var (
+		out T
+		err error
+	)
+	for i, m := range chain {
+		if i > 0 {
+			log.Printf("llm: %s unavailable (%v), falling back to %s", chain[i-1], err, m)
+		}
+		out, err = resilience.DoValue(ctx, "llm:"+m, func(ctx context.Context) (T, error) {
+			return fn(ctx, m)
+		})
+		if err == nil || !resilience.ShouldFallback(err) || ctx.Err() != nil {
+			return out, m, err
+		}
+	}
+	return out, chain[len(chain)-1], err
+}
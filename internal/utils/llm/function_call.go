@@ -0,0 +1,46 @@
+package llm
+
+import (
+	"context"
+	"time"
+)
+
+// GenerateFunctionCall sends query with funcs and forces the model to answer
+// with exactly one call to one of them.
+func GenerateFunctionCall(ctx context.Context, query string, funcs []Function) (*FunctionCall, error) {
+	started := time.Now()
+
+	modelName, err := modelFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := []Part{TextPart(query)}
+	entry := cacheFor(ctx, "function", modelName, parts, funcs)
+	if entry != nil {
+		if cached, ok := entry.get(ctx); ok && cached.Call != nil {
+			recordCachedCall(ctx, modelName, started)
+			return cached.Call, nil
+		}
+	}
+
+	call, used, err := withFallbacks(ctx, modelName, func(ctx context.Context, model string) (*FunctionCall, error) {
+		provider, providerModel, err := resolve(model)
+		if err != nil {
+			return nil, err
+		}
+
+		attempted := time.Now()
+		call, usage, err := provider.CallFunction(ctx, Request{Model: providerModel, Parts: parts}, funcs)
+		recordCall(ctx, model, usage, attempted)
+		return call, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if entry != nil && used == modelName {
+		entry.put(ctx, cachedResponse{Call: call})
+	}
+	return call, nil
+}
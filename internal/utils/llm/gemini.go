@@ -0,0 +1,364 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+
+	"agios/internal/utils/jsonschema"
+)
+
+type geminiProvider struct {
+	pool *clientPool
+}
+
+// NewGeminiProvider returns the Google Gemini provider with poolSize clients
+// opened up front. Without an API key every call fails, so deployments using
+// other providers need none.
+func NewGeminiProvider(apiKey string, poolSize int) (Provider, error) {
+	if apiKey == "" {
+		return &geminiProvider{}, nil
+	}
+
+	pool, err := newClientPool(apiKey, poolSize)
+	if err != nil {
+		return nil, err
+	}
+	return &geminiProvider{pool: pool}, nil
+}
+
+func (p *geminiProvider) Name() string { return "gemini" }
+
+func (p *geminiProvider) Close() error {
+	if p.pool != nil {
+		p.pool.close()
+	}
+	return nil
+}
+
+func (p *geminiProvider) client() (*genai.Client, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("GEMINI_API_KEY not set")
+	}
+	return p.pool.get()
+}
+
+func (p *geminiProvider) Generate(ctx context.Context, req Request) (*Response, error) {
+	client, err := p.client()
+	if err != nil {
+		return nil, err
+	}
+	defer p.pool.put(client)
+
+	resp, err := client.GenerativeModel(req.Model).GenerateContent(ctx, geminiParts(req.Parts)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate content: %w", err)
+	}
+
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return &Response{Usage: geminiUsage(resp.UsageMetadata)}, fmt.Errorf("no content generated")
+	}
+	return &Response{Text: geminiText(resp), Usage: geminiUsage(resp.UsageMetadata)}, nil
+}
+
+func (p *geminiProvider) Stream(ctx context.Context, req Request) (ChunkStream, error) {
+	client, err := p.client()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &geminiStream{
+		pool:   p.pool,
+		client: client,
+		iter:   client.GenerativeModel(req.Model).GenerateContentStream(ctx, geminiParts(req.Parts)...),
+	}
+	// A stream abandoned by its reader still gives its client back.
+	s.stop = context.AfterFunc(ctx, s.release)
+
+	// The first response is read here so that a refused request fails the
+	// call, where it can still be retried or sent to a fallback model.
+	first, err := s.iter.Next()
+	if err != nil && err != iterator.Done {
+		s.finish()
+		return nil, err
+	}
+	s.pending = first
+	return s, nil
+}
+
+func (p *geminiProvider) CallFunction(ctx context.Context, req Request, funcs []Function) (*FunctionCall, TokenUsage, error) {
+	client, err := p.client()
+	if err != nil {
+		return nil, TokenUsage{}, err
+	}
+	defer p.pool.put(client)
+
+	decls := make([]*genai.FunctionDeclaration, len(funcs))
+	for i, f := range funcs {
+		decls[i] = &genai.FunctionDeclaration{Name: f.Name, Description: f.Description}
+		if f.Parameters != nil && len(f.Parameters.Properties) > 0 {
+			decls[i].Parameters = GenaiSchema(f.Parameters)
+		}
+	}
+
+	model := client.GenerativeModel(req.Model)
+	model.Tools = []*genai.Tool{{FunctionDeclarations: decls}}
+	model.ToolConfig = &genai.ToolConfig{
+		FunctionCallingConfig: &genai.FunctionCallingConfig{Mode: genai.FunctionCallingAny},
+	}
+
+	resp, err := model.GenerateContent(ctx, geminiParts(req.Parts)...)
+	if err != nil {
+		return nil, TokenUsage{}, fmt.Errorf("failed to generate content: %w", err)
+	}
+	usage := geminiUsage(resp.UsageMetadata)
+
+	if len(resp.Candidates) == 0 {
+		return nil, usage, fmt.Errorf("no content generated")
+	}
+
+	calls := resp.Candidates[0].FunctionCalls()
+	if len(calls) == 0 {
+		return nil, usage, ErrNoFunctionCall
+	}
+
+	return &FunctionCall{Name: calls[0].Name, Args: calls[0].Args}, usage, nil
+}
+
+func (p *geminiProvider) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	client, err := p.client()
+	if err != nil {
+		return nil, err
+	}
+	defer p.pool.put(client)
+
+	em := client.EmbeddingModel(model)
+	batch := em.NewBatch()
+	for _, t := range texts {
+		batch.AddContent(genai.Text(t))
+	}
+	resp, err := em.BatchEmbedContents(ctx, batch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed content: %w", err)
+	}
+
+	vectors := make([][]float32, len(resp.Embeddings))
+	for i, e := range resp.Embeddings {
+		if e != nil {
+			vectors[i] = e.Values
+		}
+	}
+	return vectors, nil
+}
+
+// clientPool keeps open genai clients for reuse. A client is leased for one
+// call or stream; when every client is leased a new one is opened, and
+// clients returned to a full pool are closed.
+type clientPool struct {
+	apiKey string
+	idle   chan *genai.Client
+}
+
+func newClientPool(apiKey string, size int) (*clientPool, error) {
+	p := &clientPool{apiKey: apiKey, idle: make(chan *genai.Client, size)}
+	for range size {
+		client, err := p.open()
+		if err != nil {
+			p.close()
+			return nil, err
+		}
+		p.idle <- client
+	}
+	return p, nil
+}
+
+// open creates a client. Clients outlive requests, so they are not tied to
+// a request context.
+func (p *clientPool) open() (*genai.Client, error) {
+	client, err := genai.NewClient(context.Background(), option.WithAPIKey(p.apiKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GenAI client: %w", err)
+	}
+	return client, nil
+}
+
+func (p *clientPool) get() (*genai.Client, error) {
+	select {
+	case client := <-p.idle:
+		return client, nil
+	default:
+		return p.open()
+	}
+}
+
+func (p *clientPool) put(client *genai.Client) {
+	select {
+	case p.idle <- client:
+	default:
+		client.Close()
+	}
+}
+
+func (p *clientPool) close() {
+	for {
+		select {
+		case client := <-p.idle:
+			client.Close()
+		default:
+			return
+		}
+	}
+}
+
+// geminiStream reads a streaming response. It owns a leased client and gives
+// it back once the response ends, fails, is closed or its context is done.
+type geminiStream struct {
+	pool   *clientPool
+	client *genai.Client
+	iter   *genai.GenerateContentResponseIterator
+	// pending is the response read when the stream was opened.
+	pending *genai.GenerateContentResponse
+	usage   TokenUsage
+	// stop unregisters the context callback; once guards the give-back.
+	stop func() bool
+	once sync.Once
+}
+
+// release gives the client back to the pool, once.
+func (s *geminiStream) release() {
+	s.once.Do(func() { s.pool.put(s.client) })
+}
+
+// finish releases the client from the reader's side.
+func (s *geminiStream) finish() {
+	s.stop()
+	s.release()
+}
+
+func (s *geminiStream) Next() (string, error) {
+	for {
+		resp, err := s.next()
+		if err == iterator.Done {
+			s.finish()
+			return "", io.EOF
+		}
+		if err != nil {
+			s.finish()
+			return "", err
+		}
+
+		if resp.UsageMetadata != nil {
+			s.usage = geminiUsage(resp.UsageMetadata)
+		}
+		if chunk := geminiText(resp); chunk != "" {
+			return chunk, nil
+		}
+	}
+}
+
+func (s *geminiStream) next() (*genai.GenerateContentResponse, error) {
+	if resp := s.pending; resp != nil {
+		s.pending = nil
+		return resp, nil
+	}
+	return s.iter.Next()
+}
+
+func (s *geminiStream) Usage() TokenUsage { return s.usage }
+
+func (s *geminiStream) Close() error {
+	s.finish()
+	return nil
+}
+
+func geminiParts(parts []Part) []genai.Part {
+	out := make([]genai.Part, 0, len(parts))
+	for _, p := range parts {
+		switch {
+		case p.URI != "":
+			out = append(out, genai.FileData{MIMEType: p.MIMEType, URI: p.URI})
+		case p.Data != nil:
+			out = append(out, genai.Blob{MIMEType: p.MIMEType, Data: p.Data})
+		default:
+			out = append(out, genai.Text(p.Text))
+		}
+	}
+	return out
+}
+
+// geminiText concatenates the text parts of the first candidate.
+func geminiText(resp *genai.GenerateContentResponse) string {
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return ""
+	}
+
+	text := ""
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if t, ok := part.(genai.Text); ok {
+			text += string(t)
+		}
+	}
+	return text
+}
+
+func geminiUsage(m *genai.UsageMetadata) TokenUsage {
+	if m == nil {
+		return TokenUsage{}
+	}
+	return TokenUsage{InputTokens: int(m.PromptTokenCount), OutputTokens: int(m.CandidatesTokenCount)}
+}
+
+// GenaiSchema converts a JSON schema into the OpenAPI subset genai accepts.
+func GenaiSchema(s *jsonschema.Schema) *genai.Schema {
+	if s == nil {
+		return nil
+	}
+
+	out := &genai.Schema{
+		Type:        genaiType(s.Type),
+		Description: s.Description,
+		Required:    s.Required,
+	}
+
+	if len(s.Enum) > 0 {
+		out.Format = "enum"
+		out.Enum = s.Enum
+	}
+
+	if s.Items != nil {
+		out.Items = GenaiSchema(s.Items)
+	}
+
+	if len(s.Properties) > 0 {
+		out.Properties = make(map[string]*genai.Schema, len(s.Properties))
+		for name, prop := range s.Properties {
+			out.Properties[name] = GenaiSchema(prop)
+		}
+	}
+
+	return out
+}
+
+func genaiType(t string) genai.Type {
+	switch t {
+	case "string":
+		return genai.TypeString
+	case "number":
+		return genai.TypeNumber
+	case "integer":
+		return genai.TypeInteger
+	case "boolean":
+		return genai.TypeBoolean
+	case "array":
+		return genai.TypeArray
+	case "object":
+		return genai.TypeObject
+	default:
+		return genai.TypeUnspecified
+	}
+}
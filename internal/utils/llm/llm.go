@@ -2,34 +2,20 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"mime"
 	"os"
 	"path/filepath"
-
-	"github.com/google/generative-ai-go/genai"
-	"google.golang.org/api/option"
-
-	"agios/internal/config"
+	"strings"
+	"time"
 )
 
-func newClient(ctx context.Context) (*genai.Client, error) {
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("GEMINI_API_KEY not set")
-	}
-
-	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create GenAI client: %w", err)
-	}
-	return client, nil
-}
-
-func createContentFromParts(query string, filePaths []string) ([]genai.Part, error) {
-	var parts []genai.Part
-	parts = append(parts, genai.Text(query))
+func createContentFromParts(query string, filePaths []string) ([]Part, error) {
+	var parts []Part
+	parts = append(parts, TextPart(query))
 
 	for _, filePath := range filePaths {
 		data, err := os.ReadFile(filePath)
@@ -43,87 +29,153 @@ func createContentFromParts(query string, filePaths []string) ([]genai.Part, err
 			mimeType = "application/octet-stream"
 		}
 
-		parts = append(parts, genai.ImageData(mimeType, data))
+		parts = append(parts, DataPart(mimeType, data))
 	}
 
 	return parts, nil
 }
 
 func GenerateFullResponse(ctx context.Context, query string, filePaths []string) (string, error) {
-	client, err := newClient(ctx)
-	if err != nil {
-		return "", fmt.Errorf("failed to create client: %w", err)
-	}
-
 	contents, err := createContentFromParts(query, filePaths)
 	if err != nil {
 		return "", fmt.Errorf("failed to create content parts: %w", err)
 	}
 
-	cfg, err := config.LoadConfig()
+	resp, err := Generate(ctx, contents)
 	if err != nil {
-		return "", fmt.Errorf("failed to load config: %w", err)
+		return "", err
 	}
+	return resp.Text, nil
+}
 
-	model := client.GenerativeModel(cfg.CurrentLLMModel)
+// Generate sends parts to the model of ctx and returns the full response.
+func Generate(ctx context.Context, parts []Part) (*Response, error) {
+	started := time.Now()
 
-	resp, err := model.GenerateContent(ctx, contents...)
+	modelName, err := modelFor(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate content: %w", err)
+		return nil, err
 	}
 
-	if resp != nil && len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
-		fullText := ""
-		for _, part := range resp.Candidates[0].Content.Parts {
-			fullText += fmt.Sprintf("%v", part)
+	entry := cacheFor(ctx, "generate", modelName, parts, nil)
+	if entry != nil {
+		if cached, ok := entry.get(ctx); ok {
+			recordCachedCall(ctx, modelName, started)
+			return &Response{Text: cached.Text}, nil
 		}
-		return fullText, nil
 	}
 
-	return "", fmt.Errorf("no content generated")
-}
+	resp, used, err := withFallbacks(ctx, modelName, func(ctx context.Context, model string) (*Response, error) {
+		provider, providerModel, err := resolve(model)
+		if err != nil {
+			return nil, err
+		}
 
-func GenerateStreamResponse(ctx context.Context, query string, filePaths []string) (*genai.GenerateContentResponseIterator, error) {
-	client, err := newClient(ctx)
+		attempted := time.Now()
+		resp, err := provider.Generate(ctx, Request{Model: providerModel, Parts: parts})
+		var usage TokenUsage
+		if resp != nil {
+			usage = resp.Usage
+		}
+		recordCall(ctx, model, usage, attempted)
+		return resp, err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create client: %w", err)
+		return nil, err
 	}
 
+	// Answers of a fallback model are not cached under the requested one.
+	if entry != nil && used == modelName {
+		entry.put(ctx, cachedResponse{Text: resp.Text})
+	}
+	return resp, nil
+}
+
+func GenerateStreamResponse(ctx context.Context, query string, filePaths []string) (*Stream, error) {
 	contents, err := createContentFromParts(query, filePaths)
 	if err != nil {
-		client.Close()
 		return nil, fmt.Errorf("failed to create content parts: %w", err)
 	}
 
-	cfg, err := config.LoadConfig()
+	return GenerateStream(ctx, contents)
+}
+
+// GenerateVideoStreamResponse streams a response to query with the video at
+// videoURL attached as file data. Gemini accepts public YouTube URLs directly.
+func GenerateVideoStreamResponse(ctx context.Context, query string, videoURL string) (*Stream, error) {
+	return GenerateStream(ctx, []Part{TextPart(query), URIPart("video/*", videoURL)})
+}
+
+// GenerateStream sends parts to the model of ctx and returns the streaming
+// response.
+func GenerateStream(ctx context.Context, parts []Part) (*Stream, error) {
+	started := time.Now()
+
+	modelName, err := modelFor(ctx)
 	if err != nil {
-		client.Close()
-		return nil, fmt.Errorf("failed to load config: %w", err)
+		return nil, err
 	}
 
-	model := client.GenerativeModel(cfg.CurrentLLMModel)
+	entry := cacheFor(ctx, "stream", modelName, parts, nil)
+	if entry != nil {
+		if cached, ok := entry.get(ctx); ok {
+			stream := newStream(ctx, modelName, &textStream{ctx: ctx, chunks: strings.SplitAfter(cached.Text, " ")}, started)
+			stream.cached = true
+			return stream, nil
+		}
+	}
 
-	iter := model.GenerateContentStream(ctx, contents...)
+	var opened time.Time
+	chunks, used, err := withFallbacks(ctx, modelName, func(ctx context.Context, model string) (ChunkStream, error) {
+		provider, providerModel, err := resolve(model)
+		if err != nil {
+			return nil, err
+		}
 
-	// Return the iterator and the client. The caller is responsible for closing the client
-	// after the iterator is fully consumed.
-	// A better approach might be to wrap the iterator and client in a struct
-	// that handles closing the client when the iterator is done.
-	// For simplicity here, we return both and note the responsibility.
-	// TODO: Consider wrapping iterator and client for better resource management.
-	return iter, nil
+		opened = time.Now()
+		chunks, err := provider.Stream(ctx, Request{Model: providerModel, Parts: parts})
+		if err != nil {
+			recordCall(ctx, model, TokenUsage{}, opened)
+		}
+		return chunks, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil && used == modelName {
+		chunks = &cachingStream{ChunkStream: chunks, ctx: ctx, entry: entry}
+	}
+	return newStream(ctx, used, chunks, opened), nil
 }
 
-// Example usage:
-// iter, client, err := GenerateStreamResponse(...)
-// defer client.Close() // Ensure client is closed after iterator is done
-// for {
-//     resp, err := iter.Next()
-//     if err == iterator.Done {
-//         break
-//     }
-//     if err != nil {
-//         // Handle error
-//     }
-//     // Process resp
-// }
+// ConsumeStream reads stream until it is exhausted, passing every text chunk
+// to onChunk, and closes it. It returns the concatenated text and the usage
+// the provider reported, which is also recorded for the call.
+func ConsumeStream(stream *Stream, onChunk func(chunk string) error) (string, TokenUsage, error) {
+	var fullText string
+	defer func() {
+		stream.chunks.Close()
+		if stream.cached {
+			recordCachedCall(stream.ctx, stream.model, stream.started)
+			return
+		}
+		recordCall(stream.ctx, stream.model, stream.chunks.Usage(), stream.started)
+	}()
+
+	for {
+		chunk, err := stream.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fullText, stream.chunks.Usage(), fmt.Errorf("failed to read stream: %w", err)
+		}
+
+		fullText += chunk
+		if err := onChunk(chunk); err != nil {
+			return fullText, stream.chunks.Usage(), err
+		}
+	}
+
+	return fullText, stream.chunks.Usage(), nil
+}
@@ -0,0 +1,26 @@
+package llm
+
+import "context"
+
+type modelKey struct{}
+
+// WithModel returns a context under which LLM calls use model instead of the
+// models routed to their stages. An empty model keeps the routing.
+func WithModel(ctx context.Context, model string) context.Context {
+	return context.WithValue(ctx, modelKey{}, model)
+}
+
+// modelFor returns the model name for calls made under ctx: the model set
+// with WithModel, else the one routed to the stage of ctx.
+func modelFor(ctx context.Context) (string, error) {
+	if model, ok := ctx.Value(modelKey{}).(string); ok && model != "" {
+		return model, nil
+	}
+
+	cfg, err := currentConfig()
+	if err != nil {
+		return "", err
+	}
+	stage, _ := ctx.Value(stageKey{}).(string)
+	return cfg.ModelForStage(stage), nil
+}
@@ -0,0 +1,331 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"agios/internal/utils/jsonschema"
+	"agios/internal/utils/resilience"
+)
+
+// openAIProvider talks to any server implementing the OpenAI chat
+// completions API: OpenAI, Cerebras, vLLM, Ollama and llama.cpp.
+type openAIProvider struct {
+	name    string
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+// NewOpenAIProvider returns a provider named name for the OpenAI-compatible
+// server at baseURL (e.g. "http://localhost:11434/v1"). apiKey may be empty
+// for local servers.
+func NewOpenAIProvider(name, baseURL, apiKey string) Provider {
+	return &openAIProvider{
+		name:    name,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		http:    &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+func (p *openAIProvider) Name() string { return p.name }
+
+func (p *openAIProvider) Close() error {
+	p.http.CloseIdleConnections()
+	return nil
+}
+
+type openAIRequest struct {
+	Model         string              `json:"model"`
+	Messages      []openAIMessage     `json:"messages"`
+	Stream        bool                `json:"stream,omitempty"`
+	StreamOptions *openAIStreamOption `json:"stream_options,omitempty"`
+	Tools         []openAITool        `json:"tools,omitempty"`
+	ToolChoice    string              `json:"tool_choice,omitempty"`
+}
+
+type openAIStreamOption struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIMessage struct {
+	Role string `json:"role"`
+	// Content is a string, or a list of content parts for multimodal
+	// prompts.
+	Content any `json:"content"`
+}
+
+type openAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+type openAITool struct {
+	Type     string             `json:"type"`
+	Function openAIFunctionDecl `json:"function"`
+}
+
+type openAIFunctionDecl struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	Parameters  *jsonschema.Schema `json:"parameters"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"message"`
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+func (u *openAIUsage) tokens() TokenUsage {
+	if u == nil {
+		return TokenUsage{}
+	}
+	return TokenUsage{InputTokens: u.PromptTokens, OutputTokens: u.CompletionTokens}
+}
+
+func (p *openAIProvider) Generate(ctx context.Context, req Request) (*Response, error) {
+	body, err := p.request(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp openAIResponse
+	if err := p.post(ctx, "/chat/completions", body, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return &Response{Usage: resp.Usage.tokens()}, fmt.Errorf("no content generated")
+	}
+	return &Response{Text: resp.Choices[0].Message.Content, Usage: resp.Usage.tokens()}, nil
+}
+
+func (p *openAIProvider) Stream(ctx context.Context, req Request) (ChunkStream, error) {
+	body, err := p.request(req)
+	if err != nil {
+		return nil, err
+	}
+	body.Stream = true
+	body.StreamOptions = &openAIStreamOption{IncludeUsage: true}
+
+	httpResp, err := p.send(ctx, "/chat/completions", body)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(httpResp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	return &openAIStream{body: httpResp.Body, scanner: scanner}, nil
+}
+
+func (p *openAIProvider) CallFunction(ctx context.Context, req Request, funcs []Function) (*FunctionCall, TokenUsage, error) {
+	body, err := p.request(req)
+	if err != nil {
+		return nil, TokenUsage{}, err
+	}
+	for _, f := range funcs {
+		params := f.Parameters
+		if params == nil {
+			params = &jsonschema.Schema{Type: "object"}
+		}
+		body.Tools = append(body.Tools, openAITool{
+			Type:     "function",
+			Function: openAIFunctionDecl{Name: f.Name, Description: f.Description, Parameters: params},
+		})
+	}
+	body.ToolChoice = "required"
+
+	var resp openAIResponse
+	if err := p.post(ctx, "/chat/completions", body, &resp); err != nil {
+		return nil, TokenUsage{}, err
+	}
+	usage := resp.Usage.tokens()
+
+	if len(resp.Choices) == 0 || len(resp.Choices[0].Message.ToolCalls) == 0 {
+		return nil, usage, ErrNoFunctionCall
+	}
+
+	fn := resp.Choices[0].Message.ToolCalls[0].Function
+	call := &FunctionCall{Name: fn.Name, Args: map[string]any{}}
+	if fn.Arguments != "" {
+		if err := json.Unmarshal([]byte(fn.Arguments), &call.Args); err != nil {
+			return nil, usage, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+		}
+	}
+	return call, usage, nil
+}
+
+// request builds the chat completion body for req. A prompt made only of
+// text is sent as a plain string, which every server accepts; images are
+// sent as data URLs and text files inline.
+type openAIEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+func (p *openAIProvider) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	var resp openAIEmbeddingResponse
+	if err := p.post(ctx, "/embeddings", &openAIEmbeddingRequest{Model: model, Input: texts}, &resp); err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range resp.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("%s returned an embedding for unknown input %d", p.name, d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
+
+func (p *openAIProvider) request(req Request) (*openAIRequest, error) {
+	var (
+		parts    []openAIContentPart
+		textOnly = true
+	)
+	for _, part := range req.Parts {
+		switch {
+		case part.IsText():
+			parts = append(parts, openAIContentPart{Type: "text", Text: part.Text})
+		case part.Data != nil && strings.HasPrefix(part.MIMEType, "image/"):
+			textOnly = false
+			url := "data:" + part.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(part.Data)
+			parts = append(parts, openAIContentPart{Type: "image_url", ImageURL: &openAIImageURL{URL: url}})
+		case part.Data != nil && strings.HasPrefix(part.MIMEType, "text/"):
+			parts = append(parts, openAIContentPart{Type: "text", Text: string(part.Data)})
+		default:
+			return nil, fmt.Errorf("%s: %w: %s", p.name, ErrUnsupportedPart, part.MIMEType)
+		}
+	}
+
+	msg := openAIMessage{Role: "user", Content: parts}
+	if textOnly {
+		texts := make([]string, len(parts))
+		for i, part := range parts {
+			texts[i] = part.Text
+		}
+		msg.Content = strings.Join(texts, "\n\n")
+	}
+
+	return &openAIRequest{Model: req.Model, Messages: []openAIMessage{msg}}, nil
+}
+
+func (p *openAIProvider) post(ctx context.Context, path string, body, out any) error {
+	resp, err := p.send(ctx, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", p.name, err)
+	}
+	return nil
+}
+
+// send posts body to path and returns the response once the server
+// accepted it.
+func (p *openAIProvider) send(ctx context.Context, path string, body any) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.http.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("%s request failed: %w", p.name, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, resilience.NewStatusError(p.name, resp, string(msg))
+	}
+	return resp, nil
+}
+
+// openAIStream reads a server-sent event stream of chat completion chunks.
+type openAIStream struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+	usage   TokenUsage
+}
+
+func (s *openAIStream) Next() (string, error) {
+	for s.scanner.Scan() {
+		data, ok := strings.CutPrefix(s.scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			return "", io.EOF
+		}
+
+		var chunk openAIResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return "", fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		if chunk.Usage != nil {
+			s.usage = chunk.Usage.tokens()
+		}
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			return chunk.Choices[0].Delta.Content, nil
+		}
+	}
+	if err := s.scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", io.EOF
+}
+
+func (s *openAIStream) Usage() TokenUsage { return s.usage }
+
+func (s *openAIStream) Close() error { return s.body.Close() }
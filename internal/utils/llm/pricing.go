@@ -0,0 +1,102 @@
+package llm
+
+import (
+	"log"
+	"strings"
+	"sync"
+)
+
+// Price is the list price of a model in USD per million tokens.
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// Prices maps model names to their list prices. A versioned name such as
+// "gemini-1.5-flash-002" is priced by its longest listed prefix.
+var Prices = map[string]Price{
+	"gemini-1.5-flash":    {Input: 0.075, Output: 0.30},
+	"gemini-1.5-flash-8b": {Input: 0.0375, Output: 0.15},
+	"gemini-1.5-pro":      {Input: 1.25, Output: 5.00},
+	"gemini-2.0-flash":    {Input: 0.10, Output: 0.40},
+	"gemini-2.5-flash":    {Input: 0.30, Output: 2.50},
+	"gemini-2.5-pro":      {Input: 1.25, Output: 10.00},
+
+	"gpt-4o":       {Input: 2.50, Output: 10.00},
+	"gpt-4o-mini":  {Input: 0.15, Output: 0.60},
+	"gpt-4.1":      {Input: 2.00, Output: 8.00},
+	"gpt-4.1-mini": {Input: 0.40, Output: 1.60},
+	"gpt-4.1-nano": {Input: 0.10, Output: 0.40},
+	"o3-mini":      {Input: 1.10, Output: 4.40},
+	"o4-mini":      {Input: 1.10, Output: 4.40},
+
+	"llama3.1-8b":   {Input: 0.10, Output: 0.10},
+	"llama-3.3-70b": {Input: 0.85, Output: 1.20},
+}
+
+// selfHosted lists the providers that run on our own hardware. Their calls
+// cost nothing, so their models need no price.
+var selfHosted = map[string]bool{
+	"ollama":   true,
+	"vllm":     true,
+	"llamacpp": true,
+	"fake":     true,
+}
+
+// unpriced holds the models already reported as missing from Prices.
+var unpriced sync.Map
+
+// PriceOf returns the price of model and whether it is listed. A routed
+// name such as "gemini/gemini-2.5-pro" is priced without its provider.
+func PriceOf(model string) (Price, bool) {
+	if p, ok := listedPrice(model); ok {
+		return p, true
+	}
+	if _, rest, ok := strings.Cut(model, "/"); ok {
+		return listedPrice(rest)
+	}
+	return Price{}, false
+}
+
+// Priced reports whether the calls of model are counted at their cost:
+// its price is listed or it runs on a self-hosted provider.
+func Priced(model string) bool {
+	if _, ok := PriceOf(model); ok {
+		return true
+	}
+	p, _, err := resolve(model)
+	return err == nil && selfHosted[p.Name()]
+}
+
+func listedPrice(model string) (Price, bool) {
+	if p, ok := Prices[model]; ok {
+		return p, true
+	}
+
+	best := ""
+	for name := range Prices {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return Prices[best], true
+}
+
+// Cost returns the USD cost of a call. Unlisted models cost nothing; each
+// not self-hosted is logged the first time it is priced.
+func Cost(model string, inputTokens, outputTokens int) float64 {
+	p, ok := PriceOf(model)
+	if !ok {
+		if Priced(model) {
+			return 0
+		}
+		if _, seen := unpriced.LoadOrStore(model, struct{}{}); !seen {
+			log.Printf("llm: no price listed for %s, its calls are recorded at $0", model)
+		}
+		return 0
+	}
+	return (float64(inputTokens)*p.Input + float64(outputTokens)*p.Output) / 1_000_000
+}
@@ -0,0 +1,239 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+
+	"agios/internal/config"
+	"agios/internal/utils/jsonschema"
+)
+
+// ErrUnsupportedPart is returned by a provider that cannot send a part, e.g.
+// a video URL to a text-only server.
+var ErrUnsupportedPart = errors.New("part not supported by provider")
+
+// ErrNoFunctionCall and ErrInvalidArguments are returned when a model
+// answers a function call request with text or with arguments that are
+// not a JSON object. Asking again may help; retrying the transport does not.
+var (
+	ErrNoFunctionCall   = errors.New("model returned no function call")
+	ErrInvalidArguments = errors.New("model returned invalid function arguments")
+)
+
+// Part is one piece of a multimodal prompt: text, inline data or a URI.
+type Part struct {
+	Text     string
+	MIMEType string
+	Data     []byte
+	URI      string
+}
+
+// TextPart returns a text part.
+func TextPart(text string) Part { return Part{Text: text} }
+
+// DataPart returns inline data such as an uploaded image or PDF.
+func DataPart(mimeType string, data []byte) Part { return Part{MIMEType: mimeType, Data: data} }
+
+// URIPart returns a reference to remote data, such as a YouTube URL.
+func URIPart(mimeType, uri string) Part { return Part{MIMEType: mimeType, URI: uri} }
+
+// IsText reports whether p is a text part.
+func (p Part) IsText() bool { return p.Data == nil && p.URI == "" }
+
+// TokenUsage is the token count reported for a request.
+type TokenUsage struct {
+	InputTokens  int
+	OutputTokens int
+}
+
+// Request is a single-turn generation request.
+type Request struct {
+	Model string
+	Parts []Part
+}
+
+// Response is a complete generation.
+type Response struct {
+	Text  string
+	Usage TokenUsage
+}
+
+// ChunkStream yields the text of a streaming generation.
+type ChunkStream interface {
+	// Next returns the next chunk of text, or io.EOF once the stream ends.
+	Next() (string, error)
+	// Usage is the usage reported so far; it is final after io.EOF.
+	Usage() TokenUsage
+	// Close releases the connection of the stream. It may be called more
+	// than once.
+	Close() error
+}
+
+// Function is a function the model may call.
+type Function struct {
+	Name        string
+	Description string
+	// Parameters is nil or an object schema; an object without properties
+	// takes no parameters.
+	Parameters *jsonschema.Schema
+}
+
+// FunctionCall is a call the model made.
+type FunctionCall struct {
+	Name string         `json:"name"`
+	Args map[string]any `json:"args"`
+}
+
+// Provider is an LLM backend.
+type Provider interface {
+	Name() string
+	Generate(ctx context.Context, req Request) (*Response, error)
+	Stream(ctx context.Context, req Request) (ChunkStream, error)
+	// CallFunction forces the model to answer with exactly one call to one
+	// of funcs.
+	CallFunction(ctx context.Context, req Request, funcs []Function) (*FunctionCall, TokenUsage, error)
+}
+
+var (
+	providersMu     sync.RWMutex
+	providers       = map[string]Provider{}
+	defaultProvider string
+	settings        *config.Config
+	initOnce        sync.Once
+)
+
+// Init sets up the providers configured in cfg, opening their long-lived
+// clients. It is called once at startup; LLM calls made without it set up
+// from the environment on first use.
+func Init(cfg *config.Config) error {
+	err := errors.New("LLM providers already initialised")
+	initOnce.Do(func() {
+		err = setup(cfg)
+	})
+	return err
+}
+
+// Close releases the clients of every provider.
+func Close() {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	for _, p := range providers {
+		if c, ok := p.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				log.Printf("Failed to close LLM provider %s: %v", p.Name(), err)
+			}
+		}
+	}
+}
+
+// RegisterProvider makes p available to models named "<p.Name()>/<model>".
+// With asDefault it also serves model names without a provider prefix,
+// replacing the configured LLM_PROVIDER.
+func RegisterProvider(p Provider, asDefault bool) {
+	ensureProviders()
+
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	providers[p.Name()] = p
+	if asDefault {
+		defaultProvider = p.Name()
+	}
+}
+
+// ensureProviders sets up from the environment unless Init already ran.
+func ensureProviders() {
+	initOnce.Do(func() {
+		cfg, err := config.LoadConfig()
+		if err == nil {
+			err = setup(cfg)
+		}
+		if err != nil {
+			log.Printf("Failed to set up LLM providers: %v", err)
+		}
+	})
+}
+
+func setup(cfg *config.Config) error {
+	providersMu.Lock()
+	settings = cfg
+	defaultProvider = cfg.LLMProvider
+	providersMu.Unlock()
+
+	gemini, err := NewGeminiProvider(cfg.GeminiAPIKey, cfg.GeminiClientPool)
+	if err != nil {
+		return err
+	}
+
+	configured := []Provider{gemini}
+	if cfg.CerebrasAPIKey != "" {
+		configured = append(configured, NewOpenAIProvider("cerebras", "https://api.cerebras.ai/v1", cfg.CerebrasAPIKey))
+	}
+	if cfg.OpenAIBaseURL != "" {
+		configured = append(configured, NewOpenAIProvider("openai", cfg.OpenAIBaseURL, cfg.OpenAIAPIKey))
+	}
+	if cfg.OllamaBaseURL != "" {
+		configured = append(configured, NewOpenAIProvider("ollama", cfg.OllamaBaseURL, ""))
+	}
+	if cfg.VLLMBaseURL != "" {
+		configured = append(configured, NewOpenAIProvider("vllm", cfg.VLLMBaseURL, ""))
+	}
+	if cfg.LlamaCppBaseURL != "" {
+		configured = append(configured, NewOpenAIProvider("llamacpp", cfg.LlamaCppBaseURL, ""))
+	}
+	if cfg.LLMProvider == "fake" || cfg.FakeLLMScript != "" {
+		fake, err := LoadFakeProvider(cfg.FakeLLMScript)
+		if err != nil {
+			return fmt.Errorf("failed to load fake LLM script: %w", err)
+		}
+		configured = append(configured, fake)
+	}
+
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	for _, p := range configured {
+		providers[p.Name()] = p
+	}
+	return nil
+}
+
+// currentConfig returns the configuration the providers were set up with.
+func currentConfig() (*config.Config, error) {
+	ensureProviders()
+
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	if settings == nil {
+		return nil, errors.New("LLM providers are not configured")
+	}
+	return settings, nil
+}
+
+// resolve returns the provider of model and the model name it knows it by.
+// "cerebras/llama3.1-8b" goes to the cerebras provider as "llama3.1-8b".
+func resolve(model string) (Provider, string, error) {
+	ensureProviders()
+
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	if name, rest, ok := strings.Cut(model, "/"); ok {
+		if p, ok := providers[name]; ok {
+			return p, rest, nil
+		}
+	}
+
+	p, ok := providers[defaultProvider]
+	if !ok {
+		return nil, "", fmt.Errorf("no LLM provider configured for model %q", model)
+	}
+	return p, model, nil
+}
@@ -0,0 +1,27 @@
+package llm
+
+import (
+	"context"
+	"time"
+)
+
+// Stream is a streaming response. It holds its provider connection until it
+// ends or its context is done; ConsumeStream reads it to the end, closes it
+// and records its usage.
+type Stream struct {
+	chunks  ChunkStream
+	ctx     context.Context
+	model   string
+	started time.Time
+	// cached streams replay a cached response.
+	cached bool
+}
+
+func newStream(ctx context.Context, model string, chunks ChunkStream, started time.Time) *Stream {
+	return &Stream{chunks: chunks, ctx: ctx, model: model, started: started}
+}
+
+// Next returns the next chunk of text, or io.EOF once the stream ends.
+func (s *Stream) Next() (string, error) {
+	return s.chunks.Next()
+}
@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Call is the usage of a single LLM request.
+type Call struct {
+	Stage        string    `json:"stage"`
+	Model        string    `json:"model"`
+	InputTokens  int       `json:"input_tokens"`
+	OutputTokens int       `json:"output_tokens"`
+	LatencyMS    int64     `json:"latency_ms"`
+	Cost         float64   `json:"cost_usd"`
+	StartedAt    time.Time `json:"started_at"`
+	// Cached calls were answered from the response cache and cost nothing.
+	Cached bool `json:"cached,omitempty"`
+}
+
+// Usage collects the calls made under a context. It is safe for concurrent
+// use.
+type Usage struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+type usageKey struct{}
+
+type stageKey struct{}
+
+// WithUsage returns a context under which every LLM call is recorded in u.
+func WithUsage(ctx context.Context, u *Usage) context.Context {
+	return context.WithValue(ctx, usageKey{}, u)
+}
+
+// WithStage labels the LLM calls made under ctx with a pipeline stage, which
+// also selects the model routed to it.
+func WithStage(ctx context.Context, stage string) context.Context {
+	return context.WithValue(ctx, stageKey{}, stage)
+}
+
+// Calls returns the recorded calls in the order they finished.
+func (u *Usage) Calls() []Call {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return append([]Call(nil), u.calls...)
+}
+
+// Totals sums the recorded calls.
+func (u *Usage) Totals() (inputTokens, outputTokens int, cost float64, latency time.Duration) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for _, c := range u.calls {
+		inputTokens += c.InputTokens
+		outputTokens += c.OutputTokens
+		cost += c.Cost
+		latency += time.Duration(c.LatencyMS) * time.Millisecond
+	}
+	return inputTokens, outputTokens, cost, latency
+}
+
+// stageOf returns the stage ctx was labelled with, or "other".
+func stageOf(ctx context.Context) string {
+	if stage, _ := ctx.Value(stageKey{}).(string); stage != "" {
+		return stage
+	}
+	return "other"
+}
+
+// recordCall adds a finished call to the Usage of ctx, if any. usage is zero
+// when the request failed before the model reported it.
+func recordCall(ctx context.Context, model string, usage TokenUsage, started time.Time) {
+	addCall(ctx, Call{
+		Model:        model,
+		InputTokens:  usage.InputTokens,
+		OutputTokens: usage.OutputTokens,
+		LatencyMS:    time.Since(started).Milliseconds(),
+		Cost:         Cost(model, usage.InputTokens, usage.OutputTokens),
+		StartedAt:    started,
+	})
+}
+
+// recordCachedCall adds a call answered from the cache.
+func recordCachedCall(ctx context.Context, model string, started time.Time) {
+	addCall(ctx, Call{
+		Model:     model,
+		LatencyMS: time.Since(started).Milliseconds(),
+		StartedAt: started,
+		Cached:    true,
+	})
+}
+
+func addCall(ctx context.Context, call Call) {
+	u, ok := ctx.Value(usageKey{}).(*Usage)
+	if !ok || u == nil {
+		return
+	}
+	call.Stage = stageOf(ctx)
+
+	u.mu.Lock()
+	u.calls = append(u.calls, call)
+	u.mu.Unlock()
+}
@@ -0,0 +1,199 @@
+package reader
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"golang.org/x/net/html"
+)
+
+// contentPolicy keeps the elements that can hold article text, with the
+// class and id attributes that hint at their role. Navigation, page chrome,
+// forms and embedded media are dropped with everything inside them.
+var contentPolicy = func() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements(
+		"html", "body", "article", "main", "section", "div", "span",
+		"p", "h1", "h2", "h3", "h4", "h5", "h6", "ul", "ol", "li", "dl", "dt", "dd",
+		"blockquote", "pre", "code", "table", "thead", "tbody", "tr", "th", "td",
+		"em", "strong", "b", "i", "a", "br",
+	)
+	p.AllowAttrs("class", "id").Globally()
+	p.SkipElementsContent(
+		"head", "title", "script", "style", "noscript", "template", "nav", "header", "footer", "aside",
+		"form", "button", "select", "textarea", "iframe", "object", "embed", "svg", "canvas", "video", "audio",
+	)
+	p.AddSpaceWhenStrippingTag(true)
+	return p
+}()
+
+var (
+	// boilerplate matches the class or id of ads, menus and other page
+	// furniture that is not marked up with semantic elements.
+	boilerplate = regexp.MustCompile(`(?i)(^|[\s_-])(ads?|advert\w*|banner|cookies?|consent|sidebar|comments?|promo\w*|share|social|related|newsletter|subscribe|menu|breadcrumbs?|footer|masthead|nav\w*|popup|modal|sponsor\w*|widget)($|[\s_-])`)
+	// articleHint matches the class or id of containers holding the content.
+	articleHint = regexp.MustCompile(`(?i)article|content|entry|post|story|main|body|text`)
+)
+
+// blockElements end a line of text.
+var blockElements = map[string]bool{
+	"article": true, "main": true, "section": true, "div": true, "p": true, "br": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "li": true, "dl": true, "dt": true, "dd": true,
+	"blockquote": true, "pre": true, "table": true, "tr": true,
+}
+
+// extractArticle returns the title of a page and the text of its main
+// content, found the way Readability does: page chrome is removed, every
+// paragraph scores its parent and grandparent by length and commas, and the
+// best scoring container wins.
+func extractArticle(page string) (string, string) {
+	title := pageTitle(page)
+
+	doc, err := html.Parse(strings.NewReader(contentPolicy.Sanitize(page)))
+	if err != nil {
+		return title, ""
+	}
+	pruneBoilerplate(doc)
+
+	scores := map[*html.Node]float64{}
+	var score func(n *html.Node)
+	score = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.Data == "p" || n.Data == "pre" || n.Data == "td" || n.Data == "blockquote") {
+			text := strings.TrimSpace(textOf(n))
+			if len(text) >= 25 && n.Parent != nil {
+				s := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+				scores[n.Parent] += s
+				if n.Parent.Parent != nil {
+					scores[n.Parent.Parent] += s / 2
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			score(c)
+		}
+	}
+	score(doc)
+
+	var best *html.Node
+	bestScore := 0.0
+	for n, s := range scores {
+		if n.Type != html.ElementNode {
+			continue
+		}
+		if n.Data == "article" || n.Data == "main" || articleHint.MatchString(attr(n, "class")+" "+attr(n, "id")) {
+			s *= 1.25
+		}
+		// Containers dense with links are lists of other pages.
+		s *= 1 - linkDensity(n)
+		if s > bestScore {
+			best, bestScore = n, s
+		}
+	}
+	if best == nil {
+		best = doc
+	}
+	return title, renderText(best)
+}
+
+// pageTitle returns the text of the <title> element.
+func pageTitle(page string) string {
+	z := html.NewTokenizer(strings.NewReader(page))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken:
+			if name, _ := z.TagName(); string(name) == "title" {
+				if z.Next() == html.TextToken {
+					return strings.Join(strings.Fields(string(z.Text())), " ")
+				}
+				return ""
+			}
+		}
+	}
+}
+
+// pruneBoilerplate removes elements whose class or id marks them as page
+// furniture, unless it also marks them as content.
+func pruneBoilerplate(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.ElementNode && c.Data != "body" && c.Data != "html" {
+			hints := attr(c, "class") + " " + attr(c, "id")
+			if boilerplate.MatchString(hints) && !articleHint.MatchString(hints) {
+				n.RemoveChild(c)
+				c = next
+				continue
+			}
+		}
+		pruneBoilerplate(c)
+		c = next
+	}
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func textOf(n *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// linkDensity is the share of n's text that sits inside links.
+func linkDensity(n *html.Node) float64 {
+	total := len(strings.TrimSpace(textOf(n)))
+	if total == 0 {
+		return 0
+	}
+	linked := 0
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			linked += len(strings.TrimSpace(textOf(n)))
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return min(float64(linked)/float64(total), 1)
+}
+
+// renderText returns the text of n with a blank line after every block.
+func renderText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if n.Type == html.ElementNode && blockElements[n.Data] {
+			b.WriteString("\n\n")
+		}
+	}
+	walk(n)
+	return normalizeText(b.String())
+}
@@ -0,0 +1,77 @@
+package reader
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"agios/internal/config"
+)
+
+var (
+	defaultMu     sync.RWMutex
+	defaultReader *Reader
+	pagesToRead   int
+	passagesKept  int
+	initOnce      sync.Once
+)
+
+// Init sets up the reader used by Read from cfg. It is called once at
+// startup; Read without it sets up from the environment on first use.
+func Init(cfg *config.Config) {
+	initOnce.Do(func() {
+		setup(cfg)
+	})
+}
+
+func ensureReader() {
+	initOnce.Do(func() {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			log.Printf("Failed to set up the page reader: %v", err)
+			return
+		}
+		setup(cfg)
+	})
+}
+
+func setup(cfg *config.Config) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	defaultReader = New(Options{
+		Timeout:     cfg.PageReaderTimeout,
+		MaxBytes:    cfg.PageReaderMaxBytes,
+		Concurrency: cfg.PageReaderPages,
+	})
+	pagesToRead = cfg.PageReaderPages
+	passagesKept = cfg.PageReaderPassages
+}
+
+// Enabled reports whether Read fetches pages.
+func Enabled() bool {
+	ensureReader()
+
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+
+	return defaultReader != nil && pagesToRead > 0
+}
+
+// Read fetches the first PAGE_READER_PAGES of urls and returns the
+// passages of their text most relevant to query. Page indexes in the
+// passages refer to urls.
+func Read(ctx context.Context, query string, urls []string) []Passage {
+	if !Enabled() {
+		return nil
+	}
+
+	defaultMu.RLock()
+	r, pages, keep := defaultReader, pagesToRead, passagesKept
+	defaultMu.RUnlock()
+
+	if len(urls) > pages {
+		urls = urls[:pages]
+	}
+	return Passages(r.Fetch(ctx, urls), query, keep)
+}
@@ -0,0 +1,151 @@
+package reader
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// chunkChars is the target length of a passage.
+const chunkChars = 800
+
+// Passage is a chunk of a page's text ranked against a query.
+type Passage struct {
+	// Page is the index of the page the passage comes from.
+	Page  int
+	Text  string
+	Score float64
+}
+
+// Passages splits pages into passages of about chunkChars and returns the
+// limit passages that best match query by BM25, best first. Passages that
+// share no term with the query are never returned.
+func Passages(pages []*Page, query string, limit int) []Passage {
+	var chunks []Passage
+	for i, p := range pages {
+		if p == nil {
+			continue
+		}
+		for _, c := range chunk(p.Text) {
+			chunks = append(chunks, Passage{Page: i, Text: c})
+		}
+	}
+	if len(chunks) == 0 || limit <= 0 {
+		return nil
+	}
+
+	terms := uniqueTerms(tokenize(query))
+	docs := make([]map[string]int, len(chunks))
+	lengths := make([]int, len(chunks))
+	docFreq := map[string]int{}
+	totalLen := 0
+	for i, c := range chunks {
+		tokens := tokenize(c.Text)
+		lengths[i] = len(tokens)
+		totalLen += len(tokens)
+		docs[i] = map[string]int{}
+		for _, t := range tokens {
+			docs[i][t]++
+		}
+		for t := range docs[i] {
+			docFreq[t]++
+		}
+	}
+
+	const k1, b = 1.2, 0.75
+	n := float64(len(chunks))
+	avgLen := float64(totalLen) / n
+	for i := range chunks {
+		for _, t := range terms {
+			tf := float64(docs[i][t])
+			if tf == 0 {
+				continue
+			}
+			idf := math.Log(1 + (n-float64(docFreq[t])+0.5)/(float64(docFreq[t])+0.5))
+			chunks[i].Score += idf * tf * (k1 + 1) / (tf + k1*(1-b+b*float64(lengths[i])/avgLen))
+		}
+	}
+
+	sort.SliceStable(chunks, func(i, j int) bool { return chunks[i].Score > chunks[j].Score })
+	out := chunks[:0]
+	for _, c := range chunks {
+		if c.Score <= 0 || len(out) == limit {
+			break
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// chunk groups the paragraphs of text into passages of about chunkChars,
+// splitting paragraphs that are longer on word boundaries.
+func chunk(text string) []string {
+	var chunks []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, para := range strings.Split(text, "\n\n") {
+		for len(para) > chunkChars {
+			cut := strings.LastIndexByte(para[:chunkChars], ' ')
+			if cut <= 0 {
+				for cut = chunkChars; !utf8.RuneStart(para[cut]); cut-- {
+				}
+			}
+			flush()
+			chunks = append(chunks, strings.TrimSpace(para[:cut]))
+			para = strings.TrimSpace(para[cut:])
+		}
+		if para == "" {
+			continue
+		}
+		if current.Len() > 0 && current.Len()+len(para) > chunkChars {
+			flush()
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n")
+		}
+		current.WriteString(para)
+	}
+	flush()
+	return chunks
+}
+
+// stopwords are too common to tell passages apart.
+var stopwords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "was": true, "what": true, "how": true,
+	"who": true, "why": true, "when": true, "where": true, "which": true, "with": true, "that": true,
+	"this": true, "from": true, "does": true, "did": true, "can": true, "you": true, "your": true,
+	"about": true, "into": true, "than": true, "then": true, "them": true, "they": true, "its": true,
+}
+
+func tokenize(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	tokens := fields[:0]
+	for _, f := range fields {
+		if len(f) > 1 && !stopwords[f] {
+			tokens = append(tokens, f)
+		}
+	}
+	return tokens
+}
+
+func uniqueTerms(tokens []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, t := range tokens {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
@@ -0,0 +1,271 @@
+// Package reader fetches the pages behind search results, extracts their
+// main text and picks the passages most relevant to a query.
+package reader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// UserAgent identifies the reader to the sites it fetches and selects its
+// group in robots.txt.
+const UserAgent = "AgiosBot/1.0 (+https://github.com/wvsr/agios-go)"
+
+// Errors returned for pages that are not read.
+var (
+	ErrDisallowed  = errors.New("disallowed by robots.txt")
+	ErrNotReadable = errors.New("not an HTML or text page")
+)
+
+// Options configure a Reader.
+type Options struct {
+	// Client fetches pages and robots.txt files. Nil uses a client that
+	// refuses to connect to non-public addresses.
+	Client *http.Client
+	// Timeout bounds each page, robots.txt included.
+	Timeout time.Duration
+	// MaxBytes caps the body read from a page; longer pages are cut.
+	MaxBytes int64
+	// Concurrency is the number of pages fetched at once.
+	Concurrency int
+}
+
+// Page is the readable content of a fetched URL.
+type Page struct {
+	URL   string
+	Title string
+	// Text is the main text, paragraphs separated by blank lines.
+	Text string
+}
+
+// Reader fetches pages concurrently, honouring robots.txt.
+type Reader struct {
+	opts   Options
+	robots *robotsCache
+	// pages is opts.Client checking robots.txt on every redirect as well.
+	pages *http.Client
+}
+
+// New returns a Reader; zero options take their defaults.
+func New(opts Options) *Reader {
+	if opts.Client == nil {
+		opts.Client = publicClient()
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 8 * time.Second
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = 2 << 20
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
+	}
+	r := &Reader{opts: opts, robots: newRobotsCache(opts.Client)}
+	pages := *opts.Client
+	pages.CheckRedirect = r.checkRedirect(opts.Client.CheckRedirect)
+	r.pages = &pages
+	return r
+}
+
+// checkRedirect applies the client's own redirect policy, or the default
+// limit of 10, and then robots.txt of the redirect target.
+func (r *Reader) checkRedirect(policy func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if policy != nil {
+			if err := policy(req, via); err != nil {
+				return err
+			}
+		} else if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+
+		allowed, err := r.robots.allowed(req.Context(), req.URL.String())
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return ErrDisallowed
+		}
+		return nil
+	}
+}
+
+// Fetch reads urls concurrently. The result is aligned with urls: pages
+// that failed, were disallowed or have no readable text are nil.
+func (r *Reader) Fetch(ctx context.Context, urls []string) []*Page {
+	pages := make([]*Page, len(urls))
+	sem := make(chan struct{}, r.opts.Concurrency)
+
+	var wg sync.WaitGroup
+	for i, u := range urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+
+			page, err := r.fetchPage(ctx, u)
+			if err != nil {
+				log.Printf("page reader skipped %s: %v", u, err)
+				return
+			}
+			pages[i] = page
+		}()
+	}
+	wg.Wait()
+	return pages
+}
+
+func (r *Reader) fetchPage(ctx context.Context, pageURL string) (*Page, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.opts.Timeout)
+	defer cancel()
+
+	allowed, err := r.robots.allowed(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrDisallowed
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain;q=0.8")
+
+	resp, err := r.pages.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "" {
+		mediaType = "text/html"
+	}
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" && mediaType != "text/plain" {
+		return nil, ErrNotReadable
+	}
+
+	body, err := charset.NewReader(io.LimitReader(resp.Body, r.opts.MaxBytes), contentType)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := io.ReadAll(body)
+	if err != nil && len(raw) == 0 {
+		return nil, err
+	}
+
+	page := &Page{URL: pageURL}
+	if mediaType == "text/plain" {
+		page.Text = normalizeText(string(raw))
+	} else {
+		page.Title, page.Text = extractArticle(string(raw))
+	}
+	if page.Text == "" {
+		return nil, ErrNotReadable
+	}
+	return page, nil
+}
+
+// publicClient returns a client that only connects to public addresses, so
+// that links in search results cannot reach internal services.
+func publicClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip, err := netip.ParseAddr(host)
+			if err != nil || !isPublic(ip) {
+				return fmt.Errorf("refusing to connect to %s", host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			return nil
+		},
+	}
+}
+
+// nonPublic are the ranges that are global unicast by address class but
+// not reachable on the public internet, or that embed IPv4 addresses that
+// may not be.
+var nonPublic = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved, broadcast included
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local NAT64
+	netip.MustParsePrefix("100::/64"),        // discard
+	netip.MustParsePrefix("2001::/32"),       // Teredo
+	netip.MustParsePrefix("2001:2::/48"),     // benchmarking
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4
+}
+
+// isPublic reports whether ip is a global unicast address outside the
+// private, shared and reserved ranges.
+func isPublic(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, p := range nonPublic {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeText(s string) string {
+	var paragraphs []string
+	for _, p := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n\n") {
+		if p = strings.Join(strings.Fields(p), " "); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
@@ -0,0 +1,251 @@
+package reader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+const article = `<html><head><title>Coffee</title></head><body>
+<nav>Home | About</nav>
+<article><p>Coffee is a drink brewed from roasted coffee beans, the seeds of berries.</p>
+<p>It is one of the most traded goods, grown in more than seventy countries.</p></article>
+<footer>Copyright</footer></body></html>`
+
+// newSite serves robots.txt and the pages of a test site.
+func newSite(t *testing.T, robots string, pages map[string]http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			if robots == "" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(robots))
+			return
+		}
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		page(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func serve(contentType, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte(body))
+	}
+}
+
+func TestFetchPage(t *testing.T) {
+	srv := newSite(t, "User-agent: *\nDisallow: /private\n\nUser-agent: OtherBot\nDisallow: /\n", map[string]http.HandlerFunc{
+		"/article": serve("text/html; charset=utf-8", article),
+		"/private": serve("text/html", article),
+		"/latin1":  serve("text/plain; charset=iso-8859-1", "Caf\xe9 cr\xe8me"),
+		"/long":    serve("text/plain", strings.Repeat("word ", 1000)),
+		"/image":   serve("image/png", "\x89PNG"),
+		"/moved": func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/article", http.StatusFound)
+		},
+		"/sneaky": func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/private", http.StatusFound)
+		},
+		"/slow": func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-time.After(2 * time.Second):
+			case <-r.Context().Done():
+			}
+			w.Write([]byte("too late"))
+		},
+	})
+
+	tests := []struct {
+		name     string
+		path     string
+		opts     Options
+		wantText string
+		wantErr  error
+	}{
+		{name: "article", path: "/article", wantText: "Coffee is a drink brewed from roasted coffee beans, the seeds of berries."},
+		{name: "robots disallowed", path: "/private", wantErr: ErrDisallowed},
+		{name: "redirect", path: "/moved", wantText: "Coffee is a drink"},
+		{name: "redirect to disallowed", path: "/sneaky", wantErr: ErrDisallowed},
+		{name: "charset", path: "/latin1", wantText: "Café crème"},
+		{name: "size limit", path: "/long", opts: Options{MaxBytes: 100}, wantText: strings.TrimSpace(strings.Repeat("word ", 20))},
+		{name: "not readable", path: "/image", wantErr: ErrNotReadable},
+		{name: "timeout", path: "/slow", opts: Options{Timeout: 50 * time.Millisecond}, wantErr: context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Client = srv.Client()
+			page, err := New(tt.opts).fetchPage(context.Background(), srv.URL+tt.path)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(page.Text, tt.wantText) {
+				t.Errorf("text = %q, want prefix %q", page.Text, tt.wantText)
+			}
+			if tt.opts.MaxBytes > 0 && int64(len(page.Text)) > tt.opts.MaxBytes {
+				t.Errorf("text is %d bytes, over the %d byte limit", len(page.Text), tt.opts.MaxBytes)
+			}
+		})
+	}
+}
+
+func TestFetchAlignsPages(t *testing.T) {
+	srv := newSite(t, "User-agent: AgiosBot\nDisallow: /private\n", map[string]http.HandlerFunc{
+		"/article": serve("text/html", article),
+		"/private": serve("text/html", article),
+	})
+
+	pages := New(Options{Client: srv.Client()}).Fetch(context.Background(), []string{
+		srv.URL + "/private",
+		srv.URL + "/article",
+		srv.URL + "/missing",
+	})
+	if len(pages) != 3 || pages[0] != nil || pages[1] == nil || pages[2] != nil {
+		t.Fatalf("pages = %v, want only the second", pages)
+	}
+	if pages[1].Title != "Coffee" {
+		t.Errorf("title = %q, want Coffee", pages[1].Title)
+	}
+}
+
+func TestRobotsRules(t *testing.T) {
+	robots := parseRobots(strings.NewReader(`
+User-agent: *
+Disallow: /
+
+User-agent: AgiosBot
+Disallow: /search
+Allow: /search/about
+Disallow: /*.pdf$
+`))
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/", true},
+		{"/news", true},
+		{"/search?q=x", false},
+		{"/search/about", true},
+		{"/files/report.pdf", false},
+		{"/files/report.pdf?x=1", true},
+	}
+	for _, tt := range tests {
+		if got := robots.allows(tt.path); got != tt.want {
+			t.Errorf("allows(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsCacheLimit(t *testing.T) {
+	c := newRobotsCache(nil)
+	now := time.Now()
+	for i := range robotsMaxSites {
+		c.sites[fmt.Sprintf("https://site%d.example", i)] = &robotsRules{fetched: now.Add(time.Duration(i) * time.Second)}
+	}
+	c.sites["https://site0.example"].fetched = now.Add(-time.Minute)
+
+	c.store("https://new.example", &robotsRules{fetched: now})
+	if len(c.sites) != robotsMaxSites {
+		t.Fatalf("cache holds %d sites, want %d", len(c.sites), robotsMaxSites)
+	}
+	if _, ok := c.sites["https://site0.example"]; ok {
+		t.Error("the site fetched longest ago was kept")
+	}
+
+	c.sites["https://site1.example"].fetched = now.Add(-2 * robotsTTL)
+	c.sites["https://site2.example"].fetched = now.Add(-2 * robotsTTL)
+	c.store("https://newer.example", &robotsRules{fetched: now})
+	if len(c.sites) != robotsMaxSites-1 {
+		t.Errorf("cache holds %d sites, want the two expired ones dropped", len(c.sites))
+	}
+	if _, ok := c.sites["https://site3.example"]; !ok {
+		t.Error("a live site was dropped while expired ones could go")
+	}
+}
+
+func TestPassages(t *testing.T) {
+	pages := []*Page{
+		{Text: "Bananas are yellow fruit.\n\nThey grow in bunches."},
+		nil,
+		{Text: "Coffee beans are roasted before brewing.\n\n" + strings.Repeat("Filler sentence without the query words. ", 30) + "\n\nEspresso is coffee brewed under pressure."},
+	}
+
+	got := Passages(pages, "how is coffee brewed", 5)
+	if len(got) == 0 {
+		t.Fatal("no passages")
+	}
+	for i, p := range got {
+		if p.Page != 2 {
+			t.Errorf("passage %d is from page %d, want 2", i, p.Page)
+		}
+		if i > 0 && p.Score > got[i-1].Score {
+			t.Errorf("passage %d scores %f, above the one before it", i, p.Score)
+		}
+		if !strings.Contains(strings.ToLower(p.Text), "coffee") && !strings.Contains(strings.ToLower(p.Text), "brewed") {
+			t.Errorf("passage %q shares no term with the query", p.Text)
+		}
+	}
+
+	if limited := Passages(pages, "coffee", 1); len(limited) != 1 {
+		t.Errorf("limit 1 gave %d passages", len(limited))
+	}
+	if none := Passages(pages, "submarine", 5); len(none) != 0 {
+		t.Errorf("unrelated query gave %v", none)
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"100.127.255.254", false},
+		{"0.0.0.0", false},
+		{"198.18.0.1", false},
+		{"240.0.0.1", false},
+		{"255.255.255.255", false},
+		{"224.0.0.1", false},
+		{"::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:100.64.0.1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"64:ff9b::a00:1", false},
+		{"2002:a00:1::1", false},
+	}
+	for _, tt := range tests {
+		if got := isPublic(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("isPublic(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
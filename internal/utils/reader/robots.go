@@ -0,0 +1,208 @@
+package reader
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// robotsTTL is how long a site's robots.txt is trusted.
+const robotsTTL = time.Hour
+
+// robotsMaxBytes caps the robots.txt read per site.
+const robotsMaxBytes = 512 << 10
+
+// robotsMaxSites caps the sites whose rules are kept.
+const robotsMaxSites = 1024
+
+// robotsRule is one Allow or Disallow line.
+type robotsRule struct {
+	allow   bool
+	pattern string
+	match   *regexp.Regexp
+}
+
+// robotsRules are the rules of the group that applies to UserAgent.
+type robotsRules struct {
+	rules   []robotsRule
+	fetched time.Time
+}
+
+type robotsCache struct {
+	client *http.Client
+	mu     sync.Mutex
+	sites  map[string]*robotsRules
+}
+
+func newRobotsCache(client *http.Client) *robotsCache {
+	return &robotsCache{client: client, sites: map[string]*robotsRules{}}
+}
+
+// allowed reports whether robots.txt lets UserAgent fetch pageURL. A
+// missing robots.txt (4xx) allows everything; a site whose robots.txt
+// cannot be read is not fetched.
+func (c *robotsCache) allowed(ctx context.Context, pageURL string) (bool, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return false, err
+	}
+	site := u.Scheme + "://" + u.Host
+
+	c.mu.Lock()
+	rules, ok := c.sites[site]
+	c.mu.Unlock()
+
+	if !ok || time.Since(rules.fetched) > robotsTTL {
+		rules, err = c.fetch(ctx, site)
+		if err != nil {
+			return false, err
+		}
+		c.store(site, rules)
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return rules.allows(path), nil
+}
+
+// store keeps the rules of site. A full cache first drops the expired sites
+// and, if none has expired, the one fetched longest ago.
+func (c *robotsCache) store(site string, rules *robotsRules) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.sites[site]; !ok && len(c.sites) >= robotsMaxSites {
+		oldest := ""
+		for s, r := range c.sites {
+			if time.Since(r.fetched) > robotsTTL {
+				delete(c.sites, s)
+				continue
+			}
+			if oldest == "" || r.fetched.Before(c.sites[oldest].fetched) {
+				oldest = s
+			}
+		}
+		if len(c.sites) >= robotsMaxSites {
+			delete(c.sites, oldest)
+		}
+	}
+	c.sites[site] = rules
+}
+
+func (c *robotsCache) fetch(ctx context.Context, site string) (*robotsRules, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, site+"/robots.txt", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return parseRobots(io.LimitReader(resp.Body, robotsMaxBytes)), nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return &robotsRules{fetched: time.Now()}, nil
+	default:
+		return &robotsRules{fetched: time.Now(), rules: []robotsRule{{pattern: "/", match: regexp.MustCompile("^/")}}}, nil
+	}
+}
+
+// parseRobots returns the rules of the group naming UserAgent's product
+// token, or of the "*" group if none does.
+func parseRobots(r io.Reader) *robotsRules {
+	agent := strings.ToLower(strings.SplitN(UserAgent, "/", 2)[0])
+
+	var (
+		specific, wildcard []robotsRule
+		haveSpecific       bool
+		groupAgents        []string
+		inRules            bool
+	)
+	addRule := func(rule robotsRule) {
+		for _, a := range groupAgents {
+			switch {
+			case a == "*":
+				wildcard = append(wildcard, rule)
+			case strings.Contains(agent, a):
+				specific = append(specific, rule)
+			}
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if inRules {
+				groupAgents = nil
+				inRules = false
+			}
+			a := strings.ToLower(value)
+			groupAgents = append(groupAgents, a)
+			if a != "*" && strings.Contains(agent, a) {
+				haveSpecific = true
+			}
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				continue
+			}
+			addRule(robotsRule{allow: key == "allow", pattern: value, match: robotsPattern(value)})
+		}
+	}
+
+	rules := wildcard
+	if haveSpecific {
+		rules = specific
+	}
+	return &robotsRules{rules: rules, fetched: time.Now()}
+}
+
+// robotsPattern compiles a path pattern with the "*" and "$" wildcards.
+func robotsPattern(pattern string) *regexp.Regexp {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// allows applies the longest matching rule; Allow wins a tie.
+func (r *robotsRules) allows(path string) bool {
+	allowed, longest := true, -1
+	for _, rule := range r.rules {
+		if !rule.match.MatchString(path) {
+			continue
+		}
+		if n := len(rule.pattern); n > longest || (n == longest && rule.allow) {
+			allowed, longest = rule.allow, n
+		}
+	}
+	return allowed
+}
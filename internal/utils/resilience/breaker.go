@@ -0,0 +1,94 @@
+package resilience
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Breaker states.
+const (
+	stateClosed   = "closed"
+	stateOpen     = "open"
+	stateHalfOpen = "half_open"
+)
+
+// breaker is the circuit breaker of one dependency. After Failures
+// consecutive retryable failures it opens and refuses calls for Cooldown,
+// then lets a single probe through: success closes it, failure opens it
+// again.
+type breaker struct {
+	name     string
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+var (
+	breakersMu sync.Mutex
+	breakers   = map[string]*breaker{}
+)
+
+func breakerFor(name string) *breaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+
+	b, ok := breakers[name]
+	if !ok {
+		b = &breaker{name: name, state: stateClosed}
+		breakers[name] = b
+	}
+	return b
+}
+
+// allow reports whether a call may go ahead.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		if time.Since(b.openedAt) < current().BreakerCooldown {
+			return false
+		}
+		b.state = stateHalfOpen
+		b.probing = true
+		return true
+	case stateHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// record reports the outcome of an allowed call. Failures that are not the
+// dependency's fault (failed is false) count as successes.
+func (b *breaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !failed {
+		if b.state != stateClosed {
+			log.Printf("circuit breaker for %s closed", b.name)
+		}
+		b.state = stateClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == stateHalfOpen || b.failures >= current().BreakerFailures {
+		if b.state != stateOpen {
+			log.Printf("circuit breaker for %s opened after %d failures", b.name, b.failures)
+		}
+		b.state = stateOpen
+		b.openedAt = time.Now()
+	}
+}
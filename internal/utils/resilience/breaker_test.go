@@ -0,0 +1,99 @@
+package resilience
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	withSettings(t, Settings{BreakerFailures: 3, BreakerCooldown: time.Minute})
+
+	type step struct {
+		cooled    bool // the cooldown has passed
+		wantAllow bool
+		failed    bool
+		wantState string
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "opens at the threshold",
+			steps: []step{
+				{wantAllow: true, failed: true, wantState: stateClosed},
+				{wantAllow: true, failed: true, wantState: stateClosed},
+				{wantAllow: true, failed: true, wantState: stateOpen},
+				{wantAllow: false, wantState: stateOpen},
+			},
+		},
+		{
+			name: "success resets the count",
+			steps: []step{
+				{wantAllow: true, failed: true, wantState: stateClosed},
+				{wantAllow: true, failed: true, wantState: stateClosed},
+				{wantAllow: true, failed: false, wantState: stateClosed},
+				{wantAllow: true, failed: true, wantState: stateClosed},
+			},
+		},
+		{
+			name: "probe success closes",
+			steps: []step{
+				{wantAllow: true, failed: true},
+				{wantAllow: true, failed: true},
+				{wantAllow: true, failed: true, wantState: stateOpen},
+				{cooled: true, wantAllow: true, failed: false, wantState: stateClosed},
+				{wantAllow: true, failed: true, wantState: stateClosed},
+			},
+		},
+		{
+			name: "probe failure reopens",
+			steps: []step{
+				{wantAllow: true, failed: true},
+				{wantAllow: true, failed: true},
+				{wantAllow: true, failed: true, wantState: stateOpen},
+				{cooled: true, wantAllow: true, failed: true, wantState: stateOpen},
+				{wantAllow: false, wantState: stateOpen},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &breaker{name: tt.name, state: stateClosed}
+			for i, s := range tt.steps {
+				if s.cooled {
+					b.openedAt = time.Now().Add(-2 * time.Minute)
+				}
+				if got := b.allow(); got != s.wantAllow {
+					t.Fatalf("step %d: allow = %v, want %v", i, got, s.wantAllow)
+				}
+				if s.wantAllow {
+					b.record(s.failed)
+				}
+				if s.wantState != "" && b.state != s.wantState {
+					t.Fatalf("step %d: state = %s, want %s", i, b.state, s.wantState)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerSingleProbe(t *testing.T) {
+	withSettings(t, Settings{BreakerFailures: 1, BreakerCooldown: time.Minute})
+
+	b := &breaker{name: "probe", state: stateClosed}
+	b.allow()
+	b.record(true)
+	b.openedAt = time.Now().Add(-2 * time.Minute)
+
+	if !b.allow() {
+		t.Fatal("the probe after the cooldown was refused")
+	}
+	if b.state != stateHalfOpen {
+		t.Errorf("state = %s, want %s", b.state, stateHalfOpen)
+	}
+	if b.allow() {
+		t.Error("a second call was let through while the probe runs")
+	}
+}
@@ -0,0 +1,99 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// ErrCircuitOpen is returned without calling a dependency whose circuit
+// breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// StatusError is an HTTP error response from a dependency.
+type StatusError struct {
+	Service string
+	Code    int
+	Body    string
+	// RetryAfter is the delay the server asked for, if any.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("%s returned status %d", e.Service, e.Code)
+	}
+	return fmt.Sprintf("%s returned status %d: %s", e.Service, e.Code, e.Body)
+}
+
+// NewStatusError builds a StatusError from a failed response. body is the
+// beginning of the response body, if it was read.
+func NewStatusError(service string, resp *http.Response, body string) *StatusError {
+	err := &StatusError{Service: service, Code: resp.StatusCode, Body: strings.TrimSpace(body)}
+	if secs, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil && secs > 0 {
+		err.RetryAfter = time.Duration(secs) * time.Second
+	}
+	return err
+}
+
+// httpCoder is implemented by the errors of Google API clients.
+type httpCoder interface {
+	HTTPCode() int
+}
+
+// Retryable reports whether a call that failed with err may succeed when
+// repeated: rate limiting (429), server errors (5xx) and timeouts. The
+// caller's own cancellation is never retryable.
+func Retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return retryableStatus(statusErr.Code)
+	}
+	var googleErr *googleapi.Error
+	if errors.As(err, &googleErr) {
+		return retryableStatus(googleErr.Code)
+	}
+	var coder httpCoder
+	if errors.As(err, &coder) {
+		return retryableStatus(coder.HTTPCode())
+	}
+	return false
+}
+
+// ShouldFallback reports whether a fallback should be tried after err: the
+// dependency is unavailable rather than the request being wrong.
+func ShouldFallback(err error) bool {
+	return errors.Is(err, ErrCircuitOpen) || Retryable(err)
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusRequestTimeout || code >= 500
+}
+
+// retryAfter returns the delay err asks for, if any.
+func retryAfter(err error) time.Duration {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.RetryAfter
+	}
+	return 0
+}
@@ -0,0 +1,78 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+type codedError int
+
+func (e codedError) Error() string { return fmt.Sprintf("status %d", int(e)) }
+func (e codedError) HTTPCode() int { return int(e) }
+
+func TestRetryable(t *testing.T) {
+	status := func(code int) error { return &StatusError{Service: "test", Code: code} }
+
+	tests := []struct {
+		name         string
+		err          error
+		wantRetry    bool
+		wantFallback bool
+	}{
+		{name: "nil", err: nil},
+		{name: "bad request", err: status(http.StatusBadRequest)},
+		{name: "unauthorized", err: status(http.StatusUnauthorized)},
+		{name: "not found", err: status(http.StatusNotFound)},
+		{name: "request timeout", err: status(http.StatusRequestTimeout), wantRetry: true, wantFallback: true},
+		{name: "rate limited", err: status(http.StatusTooManyRequests), wantRetry: true, wantFallback: true},
+		{name: "server error", err: status(http.StatusInternalServerError), wantRetry: true, wantFallback: true},
+		{name: "unavailable", err: status(http.StatusServiceUnavailable), wantRetry: true, wantFallback: true},
+		{name: "wrapped status", err: fmt.Errorf("search: %w", status(http.StatusBadGateway)), wantRetry: true, wantFallback: true},
+		{name: "google api", err: &googleapi.Error{Code: http.StatusTooManyRequests}, wantRetry: true, wantFallback: true},
+		{name: "google api client error", err: &googleapi.Error{Code: http.StatusForbidden}},
+		{name: "http coder", err: codedError(http.StatusBadGateway), wantRetry: true, wantFallback: true},
+		{name: "network timeout", err: timeoutError{}, wantRetry: true, wantFallback: true},
+		{name: "deadline exceeded", err: context.DeadlineExceeded, wantRetry: true, wantFallback: true},
+		{name: "canceled", err: context.Canceled},
+		{name: "wrapped canceled", err: fmt.Errorf("llm: %w", context.Canceled)},
+		{name: "circuit open", err: fmt.Errorf("llm: %w", ErrCircuitOpen), wantFallback: true},
+		{name: "plain error", err: errors.New("bad response")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Retryable(tt.err); got != tt.wantRetry {
+				t.Errorf("Retryable = %v, want %v", got, tt.wantRetry)
+			}
+			if got := ShouldFallback(tt.err); got != tt.wantFallback {
+				t.Errorf("ShouldFallback = %v, want %v", got, tt.wantFallback)
+			}
+		})
+	}
+}
+
+func TestNewStatusError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Retry-After", "7")
+	rec.WriteHeader(http.StatusTooManyRequests)
+
+	err := NewStatusError("searxng", rec.Result(), "  slow down \n")
+	if err.Code != http.StatusTooManyRequests || err.Body != "slow down" {
+		t.Errorf("err = %+v", err)
+	}
+	if got := retryAfter(fmt.Errorf("wrapped: %w", err)); got != 7*time.Second {
+		t.Errorf("retryAfter = %v, want 7s", got)
+	}
+}
@@ -0,0 +1,124 @@
+// Package resilience retries calls to external dependencies with backoff and
+// guards each dependency with a circuit breaker.
+package resilience
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"agios/internal/config"
+)
+
+// Settings tune retries and circuit breakers.
+type Settings struct {
+	// MaxAttempts includes the first call.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// BreakerFailures consecutive failures open a dependency's breaker for
+	// BreakerCooldown.
+	BreakerFailures int
+	BreakerCooldown time.Duration
+}
+
+var (
+	settingsMu sync.RWMutex
+	settings   = Settings{
+		MaxAttempts:     3,
+		BaseDelay:       250 * time.Millisecond,
+		MaxDelay:        5 * time.Second,
+		BreakerFailures: 5,
+		BreakerCooldown: 30 * time.Second,
+	}
+)
+
+// Configure applies the retry and breaker settings of cfg. It is called once
+// at startup.
+func Configure(cfg *config.Config) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+
+	settings = Settings{
+		MaxAttempts:     cfg.RetryMaxAttempts,
+		BaseDelay:       cfg.RetryBaseDelay,
+		MaxDelay:        cfg.RetryMaxDelay,
+		BreakerFailures: cfg.BreakerFailures,
+		BreakerCooldown: cfg.BreakerCooldown,
+	}
+}
+
+func current() Settings {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+
+	return settings
+}
+
+// Do calls fn on behalf of the dependency name until it succeeds, fails with
+// an error that is not Retryable, or runs out of attempts. Attempts are
+// spaced by exponential backoff with full jitter, or by the delay the
+// dependency asked for. While the dependency's breaker is open Do returns
+// ErrCircuitOpen without calling fn.
+func Do(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	s := current()
+	b := breakerFor(name)
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if !b.allow() {
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("%s: %w", name, ErrCircuitOpen)
+		}
+
+		err = fn(ctx)
+		retryable := Retryable(err) && ctx.Err() == nil
+		b.record(retryable)
+		if !retryable || attempt >= s.MaxAttempts {
+			return err
+		}
+
+		delay := backoff(s, attempt)
+		if after := retryAfter(err); after > delay {
+			delay = min(after, s.MaxDelay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// DoValue is Do for calls that return a value.
+func DoValue[T any](ctx context.Context, name string, fn func(ctx context.Context) (T, error)) (T, error) {
+	var out T
+	err := Do(ctx, name, func(ctx context.Context) error {
+		v, err := fn(ctx)
+		if err == nil {
+			out = v
+		}
+		return err
+	})
+	return out, err
+}
+
+// backoff returns a random delay of up to BaseDelay*2^(attempt-1), capped
+// at MaxDelay.
+func backoff(s Settings, attempt int) time.Duration {
+	ceiling := s.BaseDelay << (attempt - 1)
+	if ceiling <= 0 || ceiling > s.MaxDelay {
+		ceiling = s.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling)
+}
@@ -0,0 +1,120 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// withSettings applies s, with fresh breakers, for the duration of a test.
+func withSettings(t *testing.T, s Settings) {
+	t.Helper()
+
+	breakersMu.Lock()
+	breakers = map[string]*breaker{}
+	breakersMu.Unlock()
+
+	settingsMu.Lock()
+	saved := settings
+	settings = s
+	settingsMu.Unlock()
+
+	t.Cleanup(func() {
+		settingsMu.Lock()
+		settings = saved
+		settingsMu.Unlock()
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	s := Settings{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	tests := []struct {
+		attempt int
+		ceiling time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{40, time.Second},
+		{70, time.Second},
+	}
+	for _, tt := range tests {
+		var below time.Duration
+		for range 200 {
+			d := backoff(s, tt.attempt)
+			if d < 0 || d >= tt.ceiling {
+				t.Fatalf("backoff(attempt %d) = %v, want in [0, %v)", tt.attempt, d, tt.ceiling)
+			}
+			if d < tt.ceiling/2 {
+				below++
+			}
+		}
+		// Full jitter spreads delays over the whole range.
+		if below == 0 || below == 200 {
+			t.Errorf("backoff(attempt %d) is not jittered over [0, %v)", tt.attempt, tt.ceiling)
+		}
+	}
+
+	if d := backoff(Settings{}, 1); d != 0 {
+		t.Errorf("backoff without delays = %v, want 0", d)
+	}
+}
+
+func TestDo(t *testing.T) {
+	withSettings(t, Settings{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, BreakerFailures: 5, BreakerCooldown: time.Minute})
+
+	unavailable := &StatusError{Service: "test", Code: http.StatusServiceUnavailable}
+	badRequest := &StatusError{Service: "test", Code: http.StatusBadRequest}
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "success", errs: []error{nil}, wantCalls: 1},
+		{name: "retried until success", errs: []error{unavailable, unavailable, nil}, wantCalls: 3},
+		{name: "out of attempts", errs: []error{unavailable, unavailable, unavailable, nil}, wantCalls: 3, wantErr: unavailable},
+		{name: "not retryable", errs: []error{badRequest, nil}, wantCalls: 1, wantErr: badRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := Do(context.Background(), "do:"+tt.name, func(ctx context.Context) error {
+				calls++
+				return tt.errs[calls-1]
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestDoOpenBreaker(t *testing.T) {
+	withSettings(t, Settings{MaxAttempts: 1, BreakerFailures: 2, BreakerCooldown: time.Minute})
+
+	fail := func(ctx context.Context) error {
+		return &StatusError{Service: "test", Code: http.StatusBadGateway}
+	}
+	for range 2 {
+		Do(context.Background(), "do:open", fail)
+	}
+
+	called := false
+	err := Do(context.Background(), "do:open", func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, ErrCircuitOpen) || called {
+		t.Errorf("err = %v, called = %v; want ErrCircuitOpen without a call", err, called)
+	}
+}
@@ -0,0 +1,65 @@
+package search
+
+import (
+	"context"
+	"html"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+const braveSearchURL = "https://api.search.brave.com/res/v1/web/search"
+
+// stripTags removes the <strong> highlights Brave puts in its snippets.
+var stripTags = bluemonday.StrictPolicy()
+
+type braveProvider struct {
+	apiKey string
+}
+
+// NewBraveProvider returns a provider for the Brave Search API.
+func NewBraveProvider(apiKey string) Provider {
+	return &braveProvider{apiKey: apiKey}
+}
+
+func (p *braveProvider) Name() string { return "brave" }
+
+type braveResponse struct {
+	Web struct {
+		Results []struct {
+			Title       string `json:"title"`
+			URL         string `json:"url"`
+			Description string `json:"description"`
+			PageAge     string `json:"page_age"`
+		} `json:"results"`
+	} `json:"web"`
+}
+
+func (p *braveProvider) Search(ctx context.Context, query string, limit int) (*Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, braveSearchURL+"?"+url.Values{
+		"q":     {query},
+		"count": {strconv.Itoa(min(limit, 20))},
+	}.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Subscription-Token", p.apiKey)
+
+	var parsed braveResponse
+	if err := doJSON(req, p.Name(), &parsed); err != nil {
+		return nil, err
+	}
+
+	out := &Response{}
+	for _, r := range parsed.Web.Results {
+		out.Results = append(out.Results, Result{
+			Title:       html.UnescapeString(stripTags.Sanitize(r.Title)),
+			URL:         r.URL,
+			Content:     html.UnescapeString(stripTags.Sanitize(r.Description)),
+			PublishedAt: r.PageAge,
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,42 @@
+package search
+
+import (
+	"net/url"
+	"strings"
+)
+
+// trackingParams are query parameters that do not change the page.
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "msclkid": true, "mc_cid": true, "mc_eid": true,
+	"ref": true, "ref_src": true, "igshid": true, "si": true,
+}
+
+// CanonicalURL returns the key under which two URLs of the same page
+// collide: the scheme, "www.", default ports, fragments, tracking
+// parameters and trailing slashes are dropped and the query is sorted. It
+// returns "" for URLs that cannot be parsed.
+func CanonicalURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return ""
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+
+	query := u.Query()
+	for name := range query {
+		if strings.HasPrefix(strings.ToLower(name), "utm_") || trackingParams[strings.ToLower(name)] {
+			query.Del(name)
+		}
+	}
+
+	path := strings.TrimRight(u.EscapedPath(), "/")
+	key := host + path
+	if encoded := query.Encode(); encoded != "" {
+		key += "?" + encoded
+	}
+	return key
+}
@@ -0,0 +1,33 @@
+package search
+
+import "testing"
+
+func TestCanonicalURL(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"plain", "https://example.com/a/b", "example.com/a/b"},
+		{"scheme and www", "http://www.Example.com/a", "example.com/a"},
+		{"trailing slash", "https://example.com/a/", "example.com/a"},
+		{"root", "https://example.com/", "example.com"},
+		{"fragment", "https://example.com/a#section-2", "example.com/a"},
+		{"default port", "https://example.com:443/a", "example.com/a"},
+		{"other port", "http://example.com:8080/a", "example.com:8080/a"},
+		{"utm parameters", "https://example.com/a?utm_source=x&UTM_Medium=y", "example.com/a"},
+		{"tracking parameters", "https://example.com/a?fbclid=1&gclid=2&ref=hn&si=3", "example.com/a"},
+		{"kept parameters", "https://example.com/watch?v=abc&utm_campaign=z", "example.com/watch?v=abc"},
+		{"sorted query", "https://example.com/s?b=2&a=1", "example.com/s?a=1&b=2"},
+		{"whitespace", "  https://example.com/a  ", "example.com/a"},
+		{"no host", "/relative/path", ""},
+		{"unparsable", "http://[::1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanonicalURL(tt.raw); got != tt.want {
+				t.Errorf("CanonicalURL(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+const exaSearchURL = "https://api.exa.ai/search"
+
+// exaSnippetChars caps the page text Exa returns per result.
+const exaSnippetChars = 1000
+
+type exaProvider struct {
+	apiKey string
+}
+
+// NewExaProvider returns a provider for the Exa search API.
+func NewExaProvider(apiKey string) Provider {
+	return &exaProvider{apiKey: apiKey}
+}
+
+func (p *exaProvider) Name() string { return "exa" }
+
+type exaResponse struct {
+	Results []struct {
+		Title         string `json:"title"`
+		URL           string `json:"url"`
+		Text          string `json:"text"`
+		PublishedDate string `json:"publishedDate"`
+	} `json:"results"`
+}
+
+func (p *exaProvider) Search(ctx context.Context, query string, limit int) (*Response, error) {
+	body, err := json.Marshal(map[string]any{
+		"query":      query,
+		"numResults": limit,
+		"contents": map[string]any{
+			"text": map[string]any{"maxCharacters": exaSnippetChars},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, exaSearchURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", p.apiKey)
+
+	var parsed exaResponse
+	if err := doJSON(req, p.Name(), &parsed); err != nil {
+		return nil, err
+	}
+
+	out := &Response{}
+	for _, r := range parsed.Results {
+		out.Results = append(out.Results, Result{Title: r.Title, URL: r.URL, Content: r.Text, PublishedAt: r.PublishedDate})
+	}
+	return out, nil
+}
@@ -0,0 +1,80 @@
+package search
+
+import "sort"
+
+// rrfK dampens the weight of the top ranks in reciprocal rank fusion; 60 is
+// the value from the original paper.
+const rrfK = 60
+
+type ranking struct {
+	provider string
+	resp     *Response
+}
+
+// fuse merges the rankings of several providers. A result scores
+// 1/(rrfK+rank) for every provider that returned it, so pages found by
+// several providers rise above pages only one of them ranked highly.
+// Duplicates are detected by canonical URL and keep the first title and
+// the longest snippet seen.
+func fuse(rankings []ranking, limit int) *Response {
+	merged := &Response{}
+	byURL := map[string]*Result{}
+	var order []string
+
+	for _, r := range rankings {
+		if merged.Answer == "" {
+			merged.Answer = r.resp.Answer
+		}
+		merged.Images = append(merged.Images, r.resp.Images...)
+
+		for rank, res := range r.resp.Results {
+			key := CanonicalURL(res.URL)
+			if key == "" {
+				continue
+			}
+
+			existing, ok := byURL[key]
+			if !ok {
+				first := res
+				first.Score = 0
+				first.Providers = nil
+				existing = &first
+				byURL[key] = existing
+				order = append(order, key)
+			} else {
+				if len(res.Content) > len(existing.Content) {
+					existing.Content = res.Content
+				}
+				if existing.Title == "" {
+					existing.Title = res.Title
+				}
+				if existing.PublishedAt == "" {
+					existing.PublishedAt = res.PublishedAt
+				}
+			}
+			existing.Score += 1.0 / float64(rrfK+rank+1)
+			existing.Providers = appendUnique(existing.Providers, r.provider)
+		}
+	}
+
+	merged.Results = make([]Result, 0, len(order))
+	for _, key := range order {
+		merged.Results = append(merged.Results, *byURL[key])
+	}
+	sort.SliceStable(merged.Results, func(i, j int) bool {
+		return merged.Results[i].Score > merged.Results[j].Score
+	})
+	if limit > 0 && len(merged.Results) > limit {
+		merged.Results = merged.Results[:limit]
+	}
+	return merged
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
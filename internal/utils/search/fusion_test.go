@@ -0,0 +1,99 @@
+package search
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func results(urls ...string) []Result {
+	out := make([]Result, len(urls))
+	for i, u := range urls {
+		out[i] = Result{Title: u, URL: u, Content: "snippet"}
+	}
+	return out
+}
+
+func TestFuseRanking(t *testing.T) {
+	merged := fuse([]ranking{
+		{provider: "tavily", resp: &Response{Results: results("https://a.com", "https://b.com", "https://c.com")}},
+		{provider: "brave", resp: &Response{Results: results("https://c.com", "https://b.com", "https://d.com")}},
+	}, 0)
+
+	var got []string
+	for _, r := range merged.Results {
+		got = append(got, r.URL)
+	}
+	// Pages both providers found rank above a first place only one gave:
+	// c scores 1/61+1/63, b 2/62, a 1/61.
+	want := []string{"https://c.com", "https://b.com", "https://a.com", "https://d.com"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("order = %v, want %v", got, want)
+	}
+
+	scores := map[string]float64{
+		"https://b.com": 2.0 / 62,
+		"https://c.com": 1.0/61 + 1.0/63,
+		"https://a.com": 1.0 / 61,
+		"https://d.com": 1.0 / 63,
+	}
+	for _, r := range merged.Results {
+		if math.Abs(r.Score-scores[r.URL]) > 1e-12 {
+			t.Errorf("score of %s = %f, want %f", r.URL, r.Score, scores[r.URL])
+		}
+	}
+	if p := merged.Results[1].Providers; !slices.Equal(p, []string{"tavily", "brave"}) {
+		t.Errorf("providers of b = %v, want [tavily brave]", p)
+	}
+	if p := merged.Results[3].Providers; !slices.Equal(p, []string{"brave"}) {
+		t.Errorf("providers of d = %v, want [brave]", p)
+	}
+}
+
+func TestFuseDedup(t *testing.T) {
+	merged := fuse([]ranking{
+		{provider: "tavily", resp: &Response{
+			Answer: "first answer",
+			Results: []Result{
+				{Title: "Paris", URL: "https://www.example.com/paris/?utm_source=t", Content: "short", Score: 0.9},
+				{Title: "Bad", URL: "::not a url"},
+			},
+		}},
+		{provider: "brave", resp: &Response{
+			Answer: "second answer",
+			Results: []Result{
+				{Title: "Paris again", URL: "http://example.com/paris#top", Content: "a longer snippet", PublishedAt: "2025-01-01"},
+			},
+		}},
+		{provider: "tavily", resp: &Response{Results: results("https://example.com/paris")}},
+	}, 0)
+
+	if len(merged.Results) != 1 {
+		t.Fatalf("results = %+v, want one", merged.Results)
+	}
+	r := merged.Results[0]
+	if r.URL != "https://www.example.com/paris/?utm_source=t" || r.Title != "Paris" {
+		t.Errorf("kept %q %q, want the first URL and title", r.URL, r.Title)
+	}
+	if r.Content != "a longer snippet" || r.PublishedAt != "2025-01-01" {
+		t.Errorf("content %q published %q, want the longest snippet and the first date", r.Content, r.PublishedAt)
+	}
+	if !slices.Equal(r.Providers, []string{"tavily", "brave"}) {
+		t.Errorf("providers = %v, want [tavily brave]", r.Providers)
+	}
+	if want := 3.0 / 61; math.Abs(r.Score-want) > 1e-12 {
+		t.Errorf("score = %f, want %f from three first places", r.Score, want)
+	}
+	if merged.Answer != "first answer" {
+		t.Errorf("answer = %q, want the first one", merged.Answer)
+	}
+}
+
+func TestFuseLimit(t *testing.T) {
+	merged := fuse([]ranking{
+		{provider: "exa", resp: &Response{Results: results("https://a.com", "https://b.com", "https://c.com")}},
+	}, 2)
+	if len(merged.Results) != 2 || merged.Results[0].URL != "https://a.com" {
+		t.Errorf("results = %+v, want the top two", merged.Results)
+	}
+}
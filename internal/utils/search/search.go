@@ -0,0 +1,200 @@
+// Package search queries web search providers in parallel and merges their
+// results into one ranked list.
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"agios/internal/config"
+	"agios/internal/utils/constant"
+	"agios/internal/utils/llm"
+	"agios/internal/utils/resilience"
+)
+
+// Result is a search result in the schema shared by every provider.
+type Result struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Content string `json:"content"`
+	// Score is the fused rank score; higher is better.
+	Score       float64 `json:"score"`
+	PublishedAt string  `json:"published_at,omitempty"`
+	// Providers lists the providers that returned the result.
+	Providers []string `json:"providers"`
+}
+
+// Response is the merged answer of every provider queried.
+type Response struct {
+	Results []Result `json:"results"`
+	// Answer and Images are only returned by providers that offer them.
+	Answer string   `json:"answer"`
+	Images []string `json:"images"`
+}
+
+// Provider is a web search backend.
+type Provider interface {
+	Name() string
+	// Search returns up to limit results, best first.
+	Search(ctx context.Context, query string, limit int) (*Response, error)
+}
+
+// ErrNoProviders is returned when no search provider is configured.
+var ErrNoProviders = errors.New("no search provider configured")
+
+var (
+	providersMu sync.RWMutex
+	providers   []Provider
+	maxResults  = 10
+	timeout     = 10 * time.Second
+	concurrency = 3
+	initOnce    sync.Once
+)
+
+// Init sets up the providers configured in cfg. It is called once at
+// startup; searches made without it set up from the environment on first
+// use.
+func Init(cfg *config.Config) {
+	initOnce.Do(func() {
+		setup(cfg)
+	})
+}
+
+func ensureProviders() {
+	initOnce.Do(func() {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			log.Printf("Failed to set up search providers: %v", err)
+			return
+		}
+		setup(cfg)
+	})
+}
+
+func setup(cfg *config.Config) {
+	available := map[string]Provider{}
+	if cfg.TavilyAPIKey != "" {
+		available["tavily"] = NewTavilyProvider(cfg.TavilyAPIKey)
+	}
+	if cfg.BraveAPIKey != "" {
+		available["brave"] = NewBraveProvider(cfg.BraveAPIKey)
+	}
+	if cfg.ExaAPIKey != "" {
+		available["exa"] = NewExaProvider(cfg.ExaAPIKey)
+	}
+	if cfg.SearXNGURL != "" {
+		available["searxng"] = NewSearXNGProvider(cfg.SearXNGURL)
+	}
+
+	names := cfg.SearchProviders
+	if len(names) == 0 {
+		names = []string{"tavily", "brave", "exa", "searxng"}
+	}
+
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	providers = nil
+	for _, name := range names {
+		p, ok := available[name]
+		if !ok {
+			if len(cfg.SearchProviders) > 0 {
+				log.Printf("search provider %q is unknown or not configured", name)
+			}
+			continue
+		}
+		providers = append(providers, p)
+	}
+	maxResults = cfg.SearchMaxResults
+	timeout = cfg.SearchTimeout
+	concurrency = cfg.SearchConcurrency
+}
+
+// Search sends query to every configured provider at once and merges their
+// results: duplicates are folded by canonical URL and the list is ordered
+// by reciprocal rank fusion. Providers that fail are left out; Search only
+// fails when all of them do.
+func Search(ctx context.Context, query string) (*Response, error) {
+	return SearchAll(ctx, []string{query})
+}
+
+// SearchAll runs several queries, at most SEARCH_CONCURRENCY at a time, and
+// fuses the rankings of every query and provider into one list. It only
+// fails when every search does.
+func SearchAll(ctx context.Context, queries []string) (*Response, error) {
+	ensureProviders()
+
+	providersMu.RLock()
+	active, limit, perProvider, workers := providers, maxResults, timeout, concurrency
+	providersMu.RUnlock()
+
+	if len(active) == 0 {
+		return nil, ErrNoProviders
+	}
+
+	type search struct {
+		index    int
+		query    string
+		provider Provider
+	}
+	jobs := make(chan search)
+	rankings := make([]ranking, len(queries)*len(active))
+	errs := make([]error, len(rankings))
+
+	var wg sync.WaitGroup
+	for range min(workers*len(active), len(rankings)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				resp, err := searchOne(ctx, job.provider, job.query, limit, perProvider)
+				if err != nil {
+					errs[job.index] = fmt.Errorf("%s %q: %w", job.provider.Name(), job.query, err)
+					continue
+				}
+				rankings[job.index] = ranking{provider: job.provider.Name(), resp: resp}
+			}
+		}()
+	}
+
+	// Queries are handed out in order, so the most important one starts
+	// first; rankings keep that order whatever finishes first.
+	for qi, q := range queries {
+		for pi, p := range active {
+			jobs <- search{index: qi*len(active) + pi, query: q, provider: p}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	var found []ranking
+	for _, r := range rankings {
+		if r.resp != nil {
+			found = append(found, r)
+		}
+	}
+	if len(found) == 0 {
+		return nil, errors.Join(errs...)
+	}
+	return fuse(found, limit), nil
+}
+
+// searchOne sends query to p under p's retry policy and circuit breaker.
+func searchOne(ctx context.Context, p Provider, query string, limit int, timeout time.Duration) (*Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	resp, err := llm.Cached(ctx, constant.StageWebSearch, []any{p.Name(), query, limit}, func(ctx context.Context) (*Response, error) {
+		return resilience.DoValue(ctx, "search:"+p.Name(), func(ctx context.Context) (*Response, error) {
+			return p.Search(ctx, query, limit)
+		})
+	})
+	if err != nil {
+		log.Printf("%s search for %q failed: %v", p.Name(), query, err)
+	}
+	return resp, err
+}
@@ -0,0 +1,95 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+type stubProvider struct {
+	name    string
+	results map[string][]Result
+	err     error
+
+	mu      sync.Mutex
+	queries []string
+}
+
+func (p *stubProvider) Name() string { return p.name }
+
+func (p *stubProvider) Search(ctx context.Context, query string, limit int) (*Response, error) {
+	p.mu.Lock()
+	p.queries = append(p.queries, query)
+	p.mu.Unlock()
+
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &Response{Results: p.results[query]}, nil
+}
+
+// withProviders makes SearchAll use ps for the duration of a test.
+func withProviders(t *testing.T, ps ...Provider) {
+	t.Helper()
+	initOnce.Do(func() {})
+
+	providersMu.Lock()
+	saved := providers
+	providers, maxResults, timeout, concurrency = ps, 10, time.Second, 2
+	providersMu.Unlock()
+
+	t.Cleanup(func() {
+		providersMu.Lock()
+		providers = saved
+		providersMu.Unlock()
+	})
+}
+
+func TestSearchAll(t *testing.T) {
+	down := &stubProvider{name: "down", err: errors.New("connection refused")}
+	up := &stubProvider{name: "up", results: map[string][]Result{
+		"paris":  results("https://a.com", "https://b.com"),
+		"france": results("https://b.com", "https://c.com"),
+	}}
+	withProviders(t, down, up)
+
+	resp, err := SearchAll(context.Background(), []string{"paris", "france"})
+	if err != nil {
+		t.Fatalf("SearchAll: %v", err)
+	}
+
+	var got []string
+	for _, r := range resp.Results {
+		got = append(got, r.URL)
+	}
+	want := []string{"https://b.com", "https://a.com", "https://c.com"}
+	if !slices.Equal(got, want) {
+		t.Errorf("results = %v, want %v", got, want)
+	}
+	for _, p := range []*stubProvider{down, up} {
+		slices.Sort(p.queries)
+		if !slices.Equal(p.queries, []string{"france", "paris"}) {
+			t.Errorf("%s was asked %v, want both queries", p.name, p.queries)
+		}
+	}
+}
+
+func TestSearchAllFailures(t *testing.T) {
+	refused := errors.New("connection refused")
+	withProviders(t,
+		&stubProvider{name: "one", err: refused},
+		&stubProvider{name: "two", err: errors.New("bad key")},
+	)
+
+	if _, err := SearchAll(context.Background(), []string{"paris"}); !errors.Is(err, refused) {
+		t.Errorf("err = %v, want the joined provider errors", err)
+	}
+
+	withProviders(t)
+	if _, err := SearchAll(context.Background(), []string{"paris"}); !errors.Is(err, ErrNoProviders) {
+		t.Errorf("err = %v, want ErrNoProviders", err)
+	}
+}
@@ -0,0 +1,53 @@
+package search
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type searxngProvider struct {
+	baseURL string
+}
+
+// NewSearXNGProvider returns a provider for a self-hosted SearXNG instance
+// at baseURL. The instance must allow the json output format.
+func NewSearXNGProvider(baseURL string) Provider {
+	return &searxngProvider{baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+func (p *searxngProvider) Name() string { return "searxng" }
+
+type searxngResponse struct {
+	Results []struct {
+		Title         string `json:"title"`
+		URL           string `json:"url"`
+		Content       string `json:"content"`
+		PublishedDate string `json:"publishedDate"`
+	} `json:"results"`
+}
+
+func (p *searxngProvider) Search(ctx context.Context, query string, limit int) (*Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/search?"+url.Values{
+		"q":      {query},
+		"format": {"json"},
+	}.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed searxngResponse
+	if err := doJSON(req, p.Name(), &parsed); err != nil {
+		return nil, err
+	}
+
+	out := &Response{}
+	for i, r := range parsed.Results {
+		if i == limit {
+			break
+		}
+		out.Results = append(out.Results, Result{Title: r.Title, URL: r.URL, Content: r.Content, PublishedAt: r.PublishedDate})
+	}
+	return out, nil
+}
@@ -0,0 +1,53 @@
+package search
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+
+	"agios/internal/utils/resilience"
+
+	"github.com/strrl/tavily-go/pkg/tavily"
+)
+
+type tavilyProvider struct {
+	client *tavily.Client
+}
+
+// NewTavilyProvider returns a provider for the Tavily search API. It is
+// the only provider that returns an answer and images.
+func NewTavilyProvider(apiKey string) Provider {
+	return &tavilyProvider{client: tavily.NewClient(apiKey)}
+}
+
+func (p *tavilyProvider) Name() string { return "tavily" }
+
+func (p *tavilyProvider) Search(ctx context.Context, query string, limit int) (*Response, error) {
+	resp, err := p.client.SearchWithOptions(
+		ctx,
+		query,
+		tavily.WithMaxResults(limit),
+	)
+	if err != nil {
+		return nil, tavilyError(err)
+	}
+
+	out := &Response{Answer: resp.Answer, Images: resp.Images}
+	for _, r := range resp.Results {
+		out.Results = append(out.Results, Result{Title: r.Title, URL: r.URL, Content: r.Content})
+	}
+	return out, nil
+}
+
+var tavilyStatus = regexp.MustCompile(`response status code: (\d+), response body: (.*)`)
+
+// tavilyError turns the HTTP failures the Tavily client reports as plain
+// text into StatusErrors, so that they can be retried.
+func tavilyError(err error) error {
+	m := tavilyStatus.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	code, _ := strconv.Atoi(m[1])
+	return &resilience.StatusError{Service: "tavily", Code: code, Body: m[2]}
+}
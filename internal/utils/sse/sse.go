@@ -1,18 +1,41 @@
 package sse
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/labstack/echo/v4"
 )
 
+// HeartbeatInterval is how often an idle stream gets a comment line so that
+// proxies do not close it during slow LLM calls.
+const HeartbeatInterval = 15 * time.Second
+
+// ErrClientGone is the cancellation cause of a writer whose client disconnected.
+var ErrClientGone = errors.New("client disconnected")
+
 type SSEWriter struct {
+	mu      sync.Mutex
 	writer  http.ResponseWriter
 	flusher http.Flusher
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	done   chan struct{}
 }
 
+// SetupSSE starts the event stream and its heartbeat. The writer's context is
+// cancelled when the client disconnects or a write fails; call Close before
+// the handler returns.
 func SetupSSE(c echo.Context) (*SSEWriter, error) {
+	return setup(c, HeartbeatInterval)
+}
+
+func setup(c echo.Context, heartbeatInterval time.Duration) (*SSEWriter, error) {
 	w := c.Response().Writer
 
 	flusher, ok := w.(http.Flusher)
@@ -24,22 +47,73 @@ func SetupSSE(c echo.Context) (*SSEWriter, error) {
 	c.Response().Header().Set("Cache-Control", "no-cache")
 	c.Response().Header().Set("Connection", "keep-alive")
 	c.Response().WriteHeader(http.StatusOK)
+	flusher.Flush()
 
-	return &SSEWriter{
+	ctx, cancel := context.WithCancelCause(c.Request().Context())
+	s := &SSEWriter{
 		writer:  w,
 		flusher: flusher,
-	}, nil
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go s.heartbeat(heartbeatInterval)
+
+	return s, nil
 }
 
-func (s *SSEWriter) SendEvent(event string, data string) error {
-	_, err := fmt.Fprintf(s.writer, "event: %s\n", event)
-	if err != nil {
+// Context is cancelled once the client is gone.
+func (s *SSEWriter) Context() context.Context {
+	return s.ctx
+}
+
+// Close stops the heartbeat. Nothing is written after it returns.
+func (s *SSEWriter) Close() {
+	s.cancel(context.Canceled)
+	<-s.done
+}
+
+func (s *SSEWriter) heartbeat(interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.write(": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// write sends raw stream data and flushes it. A failed write means the
+// client is gone, so it cancels the writer's context.
+func (s *SSEWriter) write(data string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := context.Cause(s.ctx); err != nil {
 		return err
 	}
-	_, err = fmt.Fprintf(s.writer, "data: %s\n\n", data)
-	if err != nil {
+	if _, err := fmt.Fprint(s.writer, data); err != nil {
+		s.cancel(ErrClientGone)
 		return err
 	}
 	s.flusher.Flush()
 	return nil
 }
+
+func (s *SSEWriter) SendEvent(event string, data string) error {
+	return s.write(fmt.Sprintf("event: %s\ndata: %s\n\n", event, data))
+}
+
+// SendEventWithID writes an event carrying an id: field, which browsers send
+// back as Last-Event-ID when they reconnect.
+func (s *SSEWriter) SendEventWithID(id int64, event string, data string) error {
+	return s.write(fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", id, event, data))
+}
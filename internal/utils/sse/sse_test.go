@@ -0,0 +1,158 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// streamRecorder is a flushable ResponseWriter that is safe to read while
+// the heartbeat writes, and fails every write once broken is set.
+type streamRecorder struct {
+	mu     sync.Mutex
+	header http.Header
+	body   strings.Builder
+	broken bool
+}
+
+func (r *streamRecorder) Header() http.Header { return r.header }
+
+func (r *streamRecorder) WriteHeader(int) {}
+
+func (r *streamRecorder) Flush() {}
+
+func (r *streamRecorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.broken {
+		return 0, errors.New("broken pipe")
+	}
+	return r.body.Write(p)
+}
+
+func (r *streamRecorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.body.String()
+}
+
+func (r *streamRecorder) breakPipe() {
+	r.mu.Lock()
+	r.broken = true
+	r.mu.Unlock()
+}
+
+// newStream opens a stream with the given heartbeat on a request that is
+// cancelled by the returned func.
+func newStream(t *testing.T, heartbeat time.Duration) (*SSEWriter, *streamRecorder, context.CancelFunc) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx)
+	rec := &streamRecorder{header: http.Header{}}
+
+	w, err := setup(echo.New().NewContext(req, rec), heartbeat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cancel()
+		w.Close()
+	})
+	return w, rec, cancel
+}
+
+func TestSendEvent(t *testing.T) {
+	w, rec, _ := newStream(t, time.Hour)
+
+	if err := w.SendEvent("START", `{"a":1}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SendEventWithID(7, "END", `{}`); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "event: START\ndata: {\"a\":1}\n\nid: 7\nevent: END\ndata: {}\n\n"
+	if got := rec.String(); got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("content type = %q", got)
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	w, rec, _ := newStream(t, 10*time.Millisecond)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for strings.Count(rec.String(), ": heartbeat\n\n") < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("no heartbeats in %q", rec.String())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	w.Close()
+	closed := rec.String()
+	time.Sleep(30 * time.Millisecond)
+	if rec.String() != closed {
+		t.Error("the heartbeat wrote after Close")
+	}
+	if err := w.SendEvent("END", `{}`); !errors.Is(err, context.Canceled) {
+		t.Errorf("send after Close: err = %v, want context.Canceled", err)
+	}
+}
+
+func TestClientGone(t *testing.T) {
+	t.Run("failed write", func(t *testing.T) {
+		w, rec, _ := newStream(t, time.Hour)
+		rec.breakPipe()
+
+		if err := w.SendEvent("START", `{}`); err == nil {
+			t.Fatal("write to a broken stream succeeded")
+		}
+		if cause := context.Cause(w.Context()); !errors.Is(cause, ErrClientGone) {
+			t.Errorf("cause = %v, want ErrClientGone", cause)
+		}
+		if err := w.SendEvent("END", `{}`); !errors.Is(err, ErrClientGone) {
+			t.Errorf("later send: err = %v, want ErrClientGone", err)
+		}
+	})
+
+	t.Run("failed heartbeat", func(t *testing.T) {
+		w, rec, _ := newStream(t, 10*time.Millisecond)
+		rec.breakPipe()
+
+		select {
+		case <-w.Context().Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("a failed heartbeat did not cancel the stream")
+		}
+		if cause := context.Cause(w.Context()); !errors.Is(cause, ErrClientGone) {
+			t.Errorf("cause = %v, want ErrClientGone", cause)
+		}
+	})
+
+	t.Run("request cancelled", func(t *testing.T) {
+		w, _, cancel := newStream(t, time.Hour)
+		cancel()
+
+		select {
+		case <-w.Context().Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("the stream outlived its request")
+		}
+		if err := w.SendEvent("START", `{}`); err == nil {
+			t.Error("send after the client left succeeded")
+		}
+	})
+}